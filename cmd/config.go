@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+
+	"github.com/home-sol/homectl/pkg/config"
+)
+
+// configCmd executes 'config' CLI commands
+var configCmd = &cobra.Command{
+	Use:                "config",
+	Short:              "Execute 'config' commands",
+	Long:               `This command executes 'homectl config' CLI commands`,
+	FParseErrWhitelist: struct{ UnknownFlags bool }{UnknownFlags: false},
+}
+
+func init() {
+	RootCmd.AddCommand(configCmd)
+}
+
+func execConfigDescribeCommand(cmd *cobra.Command, args []string) error {
+
+	flags := cmd.Flags()
+
+	output, err := flags.GetString("output")
+	if err != nil {
+		return err
+	}
+
+	showOrigin, err := flags.GetBool("show-origin")
+	if err != nil {
+		return err
+	}
+
+	// With '--show-origin', the config keys are listed with their values and origins
+	var value interface{} = client.Describe()
+	if showOrigin {
+		value = client.Settings()
+	}
+
+	var content []byte
+	switch output {
+	case "yaml":
+		content, err = yaml.Marshal(value)
+	case "json":
+		content, err = json.MarshalIndent(value, "", "  ")
+		content = append(content, '\n')
+	default:
+		return fmt.Errorf("invalid '--output' value '%s', supported values are 'yaml' and 'json'", output)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = cmd.OutOrStdout().Write(content)
+	return err
+}
+
+func execConfigValidateCommand(cmd *cobra.Command, args []string) error {
+
+	flags := cmd.Flags()
+
+	strict, err := flags.GetBool("strict")
+	if err != nil {
+		return err
+	}
+
+	issues, err := client.Validate()
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, issue := range issues {
+		if _, err = fmt.Fprintln(cmd.OutOrStdout(), issue.String()); err != nil {
+			return err
+		}
+		if issue.Severity == config.SeverityError || strict {
+			failed++
+		}
+	}
+
+	// The non-zero exit code fails the pre-commit hooks and the CI jobs
+	if failed > 0 {
+		return fmt.Errorf("the CLI config is invalid: %d issue(s) found", failed)
+	}
+
+	return nil
+}
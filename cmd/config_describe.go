@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// configDescribeCmd executes 'config describe' CLI commands
+var configDescribeCmd = &cobra.Command{
+	Use:                "describe",
+	Short:              "Execute 'config describe' commands",
+	Long:               `This command prints the effective CLI config merged from the defaults, the config files, the ENV vars and the command-line flags`,
+	FParseErrWhitelist: struct{ UnknownFlags bool }{UnknownFlags: false},
+	Args:               cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return execConfigDescribeCommand(cmd, args)
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configDescribeCmd)
+	configDescribeCmd.PersistentFlags().StringP("output", "o", "yaml", "homectl config describe --output (yaml|json)")
+	configDescribeCmd.PersistentFlags().Bool("show-origin", false, "homectl config describe --show-origin")
+}
@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// configValidateCmd executes 'config validate' CLI commands
+var configValidateCmd = &cobra.Command{
+	Use:                "validate",
+	Short:              "Execute 'config validate' commands",
+	Long:               `This command validates the effective CLI config: the base paths exist, the stacks 'included_paths' and 'excluded_paths' match the stack files, the name patterns use the known placeholders, and the settings don't conflict. It exits with a non-zero code if any errors are found`,
+	FParseErrWhitelist: struct{ UnknownFlags bool }{UnknownFlags: false},
+	Args:               cobra.NoArgs,
+	// The issues are printed, the usage is not useful when the config is invalid
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return execConfigValidateCommand(cmd, args)
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configValidateCmd)
+	configValidateCmd.PersistentFlags().Bool("strict", false, "homectl config validate --strict (fail on the warnings too)")
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/home-sol/homectl/pkg/scaffold"
+)
+
+// initCmd executes 'init' CLI commands
+var initCmd = &cobra.Command{
+	Use:                "init [dir]",
+	Short:              "Execute 'init' commands",
+	Long:               `This command creates a new project in the folder (the current folder by default): 'homectl.yaml' with the documented defaults, the components, stacks and workflows folders, a starter component with its 'component.yaml' vendor config file, and a starter stack. It asks for the settings that are not specified with the flags, unless '--non-interactive' is given. The existing files are not overwritten unless '--force' is given`,
+	Args:               cobra.MaximumNArgs(1),
+	FParseErrWhitelist: struct{ UnknownFlags bool }{UnknownFlags: false},
+	// The usage is not useful when the files already exist
+	SilenceUsage: true,
+	// The new project doesn't need a valid config in the current folder
+	Annotations: map[string]string{withoutConfigAnnotation: ""},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return execInitCommand(cmd, args)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(initCmd)
+	// The folders are set with the global '--terraform-dir', '--helmfile-dir', '--stacks-dir' and '--workflows-dir' flags
+	initCmd.PersistentFlags().String("tenant", "", "homectl init [dir] --tenant <tenant>")
+	initCmd.PersistentFlags().String("environment", "", "homectl init [dir] --environment <environment>")
+	initCmd.PersistentFlags().String("stage", "", "homectl init [dir] --stage <stage>")
+	initCmd.PersistentFlags().StringP("component", "c", "", "homectl init [dir] --component <component>")
+	initCmd.PersistentFlags().Bool("non-interactive", false, "homectl init [dir] --non-interactive (use the flags and the defaults without asking)")
+	initCmd.PersistentFlags().Bool("force", false, "homectl init [dir] --force (overwrite the existing files)")
+}
+
+func execInitCommand(cmd *cobra.Command, args []string) error {
+
+	flags := cmd.Flags()
+
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+
+	nonInteractive, err := flags.GetBool("non-interactive")
+	if err != nil {
+		return err
+	}
+
+	options := scaffold.DefaultOptions()
+
+	if options.Force, err = flags.GetBool("force"); err != nil {
+		return err
+	}
+
+	settings := []struct {
+		flag     string
+		question string
+		value    *string
+	}{
+		{"terraform-dir", "Terraform components folder", &options.TerraformDir},
+		{"helmfile-dir", "Helmfile components folder", &options.HelmfileDir},
+		{"stacks-dir", "Stacks folder", &options.StacksDir},
+		{"workflows-dir", "Workflows folder", &options.WorkflowsDir},
+		{"tenant", "Tenant of the starter stack", &options.Tenant},
+		{"environment", "Environment of the starter stack", &options.Environment},
+		{"stage", "Stage of the starter stack", &options.Stage},
+		{"component", "Starter Terraform component", &options.Component},
+	}
+
+	// '--config-dir' is the same as '--stacks-dir'
+	if flags.Changed("config-dir") && !flags.Changed("stacks-dir") {
+		settings[2].flag = "config-dir"
+	}
+
+	reader := bufio.NewReader(cmd.InOrStdin())
+
+	for _, setting := range settings {
+		if flags.Changed(setting.flag) {
+			if *setting.value, err = flags.GetString(setting.flag); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if nonInteractive {
+			continue
+		}
+
+		if *setting.value, err = prompt(cmd, reader, setting.question, *setting.value); err != nil {
+			return err
+		}
+	}
+
+	files, err := client.Init(dir, options)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if _, err = fmt.Fprintln(cmd.OutOrStdout(), file); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// prompt asks the question with the default value, and reads the answer from the command input.
+// The empty answer (or the end of the input) keeps the default value
+func prompt(cmd *cobra.Command, reader *bufio.Reader, question string, value string) (string, error) {
+	if _, err := fmt.Fprintf(cmd.OutOrStdout(), "%s [%s]: ", question, value); err != nil {
+		return "", err
+	}
+
+	answer, err := reader.ReadString('\n')
+	if errors.Is(err, io.EOF) {
+		// The input ended without the newline, so the next output starts on its own line
+		if _, err = fmt.Fprintln(cmd.OutOrStdout()); err != nil {
+			return "", err
+		}
+	} else if err != nil {
+		return "", err
+	}
+
+	if answer = strings.TrimSpace(answer); answer == "" {
+		return value, nil
+	}
+
+	return answer, nil
+}
@@ -4,19 +4,36 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/home-sol/homectl/pkg/config"
+	"github.com/home-sol/homectl/pkg/homectl"
 	"github.com/home-sol/homectl/pkg/logger"
 )
 
+// client runs the commands with the CLI config of the current folder, it's created before running any command
+var client *homectl.Client
+
+// withoutConfigAnnotation marks the commands that don't use the CLI config, so they work without a valid 'homectl.yaml'
+const withoutConfigAnnotation = "homectl/without-config"
+
 // RootCmd represents the base command when called without any subcommands
 var RootCmd = &cobra.Command{
 	Use:   "homectl",
 	Short: "Universal Tool for Home Automation",
 	Long:  `'homectl'' is a universal tool for Home automation used for provisioning, managing and orchestrating deployment`,
+	// The errors are printed by 'main'
+	SilenceErrors: true,
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-		if err := logger.InitLogger(); err != nil {
+		l, err := logger.New()
+		if err != nil {
 			return err
 		}
-		return config.InitConfig()
+
+		options := []homectl.Option{homectl.WithContext(cmd.Context()), homectl.WithLogger(l), homectl.WithFlags(cmd.Flags())}
+		if _, ok := cmd.Annotations[withoutConfigAnnotation]; ok {
+			options = append(options, homectl.WithoutConfig())
+		}
+
+		client, err = homectl.New(options...)
+		return err
 	},
 }
 
@@ -28,6 +45,9 @@ func Execute() error {
 
 func init() {
 	cobra.OnInitialize(initConfig)
+
+	// The global flags override the config files and the ENV vars
+	config.AddFlags(RootCmd.PersistentFlags())
 }
 
 func initConfig() {
@@ -2,12 +2,12 @@ package cmd
 
 import (
 	"errors"
+	"fmt"
+	"text/tabwriter"
 
 	"github.com/spf13/cobra"
 
 	"github.com/home-sol/homectl/pkg/config"
-	"github.com/home-sol/homectl/pkg/fs"
-	"github.com/home-sol/homectl/pkg/vender"
 )
 
 // vendorCmd executes 'atmos vendor' CLI commands
@@ -45,11 +45,6 @@ func execVendorCommand(cmd *cobra.Command, args []string, vendorCommand string)
 		return errors.New("either '--component' or '--stack' parameter needs to be provided, but not both")
 	}
 
-	fss, err := fs.Cwd()
-	if err != nil {
-		return err
-	}
-
 	if component != "" {
 		// Process component vendoring
 		componentType, err := flags.GetString("type")
@@ -61,14 +56,143 @@ func execVendorCommand(cmd *cobra.Command, args []string, vendorCommand string)
 			componentType = "terraform"
 		}
 
-		componentConfig, componentPath, err := config.ReadComponentFile(fss, component, componentType)
-		if err != nil {
-			return err
+		if vendorCommand == "diff" {
+			return client.VendorDiff(component, componentType, cmd.OutOrStdout())
 		}
 
-		return vender.ExecuteComponentVendorCommand(fss, componentConfig.Spec, component, componentPath, dryRun, vendorCommand)
+		allowSecrets := false
+		if flags.Lookup("allow-secrets") != nil {
+			if allowSecrets, err = flags.GetBool("allow-secrets"); err != nil {
+				return err
+			}
+		}
+
+		return client.VendorPull(component, componentType, dryRun, allowSecrets)
 	} else {
 		// Process stack vendoring
-		return vender.ExecuteStackVendorCommand(fss, stack, dryRun, vendorCommand)
+		return client.VendorStack(stack, dryRun, vendorCommand)
+	}
+}
+
+func execVendorLsFilesCommand(cmd *cobra.Command, args []string) error {
+
+	flags := cmd.Flags()
+
+	component, err := flags.GetString("component")
+	if err != nil {
+		return err
+	}
+
+	if component == "" {
+		return errors.New("'--component' parameter needs to be provided")
+	}
+
+	componentType, err := flags.GetString("type")
+	if err != nil {
+		return err
+	}
+
+	decisions, err := client.VendorListFiles(component, componentType)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	for _, decision := range decisions {
+		status := "excluded"
+		if decision.Included {
+			status = "included"
+		}
+		if _, err = fmt.Fprintf(w, "%s\t%s\t%s\n", status, decision.Path, decision.Reason); err != nil {
+			return err
+		}
+	}
+
+	return w.Flush()
+}
+
+func execVendorAddCommand(cmd *cobra.Command, args []string) error {
+
+	flags := cmd.Flags()
+
+	component, err := flags.GetString("component")
+	if err != nil {
+		return err
+	}
+
+	if component == "" {
+		return errors.New("'--component' parameter needs to be provided")
+	}
+
+	componentType, err := flags.GetString("type")
+	if err != nil {
+		return err
+	}
+
+	source := config.VendorComponentSource{Uri: args[0]}
+
+	if source.Type, err = flags.GetString("source-type"); err != nil {
+		return err
+	}
+	if source.Version, err = flags.GetString("version"); err != nil {
+		return err
+	}
+	if source.Chart, err = flags.GetString("chart"); err != nil {
+		return err
 	}
+	if source.IncludedPaths, err = flags.GetStringArray("include"); err != nil {
+		return err
+	}
+	if source.ExcludedPaths, err = flags.GetStringArray("exclude"); err != nil {
+		return err
+	}
+
+	allowSecrets, err := flags.GetBool("allow-secrets")
+	if err != nil {
+		return err
+	}
+
+	files, err := client.VendorAdd(component, componentType, source, allowSecrets)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if _, err = fmt.Fprintln(cmd.OutOrStdout(), file); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func execVendorRemoveCommand(cmd *cobra.Command, args []string) error {
+
+	flags := cmd.Flags()
+
+	dryRun, err := flags.GetBool("dry-run")
+	if err != nil {
+		return err
+	}
+
+	component, err := flags.GetString("component")
+	if err != nil {
+		return err
+	}
+
+	if component == "" {
+		return errors.New("'--component' parameter needs to be provided")
+	}
+
+	componentType, err := flags.GetString("type")
+	if err != nil {
+		return err
+	}
+
+	force, err := flags.GetBool("force")
+	if err != nil {
+		return err
+	}
+
+	return client.VendorRemove(component, componentType, dryRun, force)
 }
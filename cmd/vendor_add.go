@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// vendorAddCmd executes 'vendor add' CLI commands
+var vendorAddCmd = &cobra.Command{
+	Use:                "add <uri>",
+	Short:              "Execute 'vendor add' commands",
+	Long:               `This command creates the component folder with the 'component.yaml' vendor config file for the upstream source, pulls the component and prints the vendored files`,
+	Args:               cobra.ExactArgs(1),
+	FParseErrWhitelist: struct{ UnknownFlags bool }{UnknownFlags: false},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return execVendorAddCommand(cmd, args)
+	},
+}
+
+func init() {
+	vendorCmd.AddCommand(vendorAddCmd)
+	vendorAddCmd.PersistentFlags().StringP("component", "c", "", "homectl vendor add <uri> --component <component>")
+	vendorAddCmd.PersistentFlags().StringP("type", "t", "terraform", "homectl vendor add <uri> --component <component> --type (terraform|helmfile)")
+	vendorAddCmd.PersistentFlags().String("source-type", "", "homectl vendor add <uri> --component <component> --source-type (oci|helm|terraform-registry)")
+	vendorAddCmd.PersistentFlags().String("version", "", "homectl vendor add <uri> --component <component> --version <version>")
+	vendorAddCmd.PersistentFlags().String("chart", "", "homectl vendor add <uri> --component <component> --source-type helm --chart <chart>")
+	vendorAddCmd.PersistentFlags().StringArray("include", nil, "homectl vendor add <uri> --component <component> --include <pattern>")
+	vendorAddCmd.PersistentFlags().Bool("allow-secrets", false, "homectl vendor add <uri> --component <component> --allow-secrets")
+	vendorAddCmd.PersistentFlags().StringArray("exclude", nil, "homectl vendor add <uri> --component <component> --exclude <pattern>")
+}
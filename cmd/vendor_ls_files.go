@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// vendorLsFilesCmd executes 'vendor ls-files' CLI commands
+var vendorLsFilesCmd = &cobra.Command{
+	Use:                "ls-files",
+	Short:              "Execute 'vendor ls-files' commands",
+	Long:               `This command lists the files of the component source and shows which 'included_paths' or 'excluded_paths' rule decided if each file is vendored`,
+	FParseErrWhitelist: struct{ UnknownFlags bool }{UnknownFlags: false},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return execVendorLsFilesCommand(cmd, args)
+	},
+}
+
+func init() {
+	vendorCmd.AddCommand(vendorLsFilesCmd)
+	vendorLsFilesCmd.PersistentFlags().StringP("component", "c", "", "homectl vendor ls-files --component <component>")
+	vendorLsFilesCmd.PersistentFlags().StringP("type", "t", "terraform", "homectl vendor ls-files --component <component> --type (terraform|helmfile)")
+}
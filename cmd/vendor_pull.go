@@ -21,4 +21,5 @@ func init() {
 	vendorPullCmd.PersistentFlags().StringP("stack", "s", "", "atmos vendor pull --stack <stack>")
 	vendorPullCmd.PersistentFlags().StringP("type", "t", "terraform", "atmos vendor pull --component <component> type=terraform/helmfile")
 	vendorPullCmd.PersistentFlags().Bool("dry-run", false, "atmos vendor pull --component <component> --dry-run")
+	vendorPullCmd.PersistentFlags().Bool("allow-secrets", false, "homectl vendor pull --component <component> --allow-secrets")
 }
@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// vendorRemoveCmd executes 'vendor remove' CLI commands
+var vendorRemoveCmd = &cobra.Command{
+	Use:                "remove",
+	Short:              "Execute 'vendor remove' commands",
+	Long:               `This command removes the vendored files, the vendoring state and the 'component.yaml' vendor config file of the component. The vendored files modified after the last pull are kept, unless '--force' is given`,
+	FParseErrWhitelist: struct{ UnknownFlags bool }{UnknownFlags: false},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return execVendorRemoveCommand(cmd, args)
+	},
+}
+
+func init() {
+	vendorCmd.AddCommand(vendorRemoveCmd)
+	vendorRemoveCmd.PersistentFlags().StringP("component", "c", "", "homectl vendor remove --component <component>")
+	vendorRemoveCmd.PersistentFlags().StringP("type", "t", "terraform", "homectl vendor remove --component <component> --type (terraform|helmfile)")
+	vendorRemoveCmd.PersistentFlags().Bool("dry-run", false, "homectl vendor remove --component <component> --dry-run")
+	vendorRemoveCmd.PersistentFlags().Bool("force", false, "homectl vendor remove --component <component> --force (remove the modified vendored files too)")
+}
@@ -12,6 +12,8 @@ var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Print the CLI version",
 	Long:  `This command prints the CLI version`,
+	// The version is printed even if the config is invalid
+	Annotations: map[string]string{withoutConfigAnnotation: ""},
 	Run: func(cmd *cobra.Command, args []string) {
 		fmt.Println(Version)
 	},
@@ -3,15 +3,29 @@ package config
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"os"
-	"path"
+	"path/filepath"
 	"runtime"
+	"strings"
+	"time"
 
 	"github.com/mitchellh/go-homedir"
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"go.uber.org/zap"
 
-	"github.com/home-sol/homectl/pkg/logger"
-	"github.com/home-sol/homectl/pkg/utils"
+	"github.com/home-sol/homectl/pkg/fs"
+)
+
+const (
+	// ConfigFileName is the name of the CLI config files
+	ConfigFileName = "homectl.yaml"
+	// ConfigFileEnvName is the ENV var with the path of the project config file
+	ConfigFileEnvName = "HOMECTL_CONFIG"
+	// ProfileEnvName is the ENV var with the name of the active profile
+	ProfileEnvName = "HOMECTL_PROFILE"
 )
 
 var (
@@ -51,26 +65,42 @@ var (
 			Verbose: false,
 			Colors:  true,
 		},
+		Vendor: Vendor{
+			TerraformRegistry: TerraformRegistry{
+				Host: "registry.terraform.io",
+			},
+			Lock: VendorLock{
+				Dir:     ".homectl/locks",
+				Timeout: 30 * time.Second,
+			},
+		},
 	}
-
-	// Config is the CLI configuration structure
-	Config Configuration
 )
 
-func InitConfig() error {
-	wd, err := os.Getwd()
-	if err != nil {
-		return err
-	}
-	return InitConfigFromDir(wd)
+// Config is the CLI configuration loaded by 'Load', with the project config file, the active profile and the origins of the values
+type Config struct {
+	Configuration
+
+	// ProjectConfigFile is the project config file, or "" if there is no project config
+	ProjectConfigFile string
+
+	// Profile is the active profile selected with '--profile' or 'HOMECTL_PROFILE', or "" if no profile is active
+	Profile string
+
+	// Origins is the source of the value of each config key (see 'ConfigKeys'), and of the active profile ('profile'):
+	// 'default', 'file:<path>', 'file:<path>#profiles.<name>', 'env:<name>' or 'flag:--<name>'
+	Origins map[string]string
 }
 
-// Init config finds and merges CLI configuration in the followinfg order system dir, home dir current dir, ENV vars, command-line args
+// Load finds and merges CLI configuration in the following order: defaults, system dir, home dir, XDG config dir,
+// project config, profile, ENV vars, command-line args. The project config is the file from '--config' or 'HOMECTL_CONFIG',
+// or 'homectl.yaml' in the dir or its closest parent. The config files are read from the file system with the absolute paths.
+// 'flags' can be nil
 // https://dev.to/techschoolguru/load-config-from-file-environment-variables-in-golang-with-viper-2j2d
 // https://medium.com/@bnprashanth256/reading-configuration-files-and-environment-variables-in-go-golang-c2607f912b63
-func InitConfigFromDir(dir string) error {
-	logger.Logger.Debugw("Processing and merging configurations in the following order:")
-	logger.Logger.Debugw("system dir, home dir, current dir, ENV vars, command-line arguments")
+func Load(fss fs.FileSystem, dir string, flags *pflag.FlagSet, l *zap.SugaredLogger) (*Config, error) {
+	l.Debugw("Processing and merging configurations in the following order:")
+	l.Debugw("system dir, home dir, XDG config dir, project dir, profile, ENV vars, command-line arguments")
 
 	v := viper.New()
 	v.SetConfigType("yaml")
@@ -79,17 +109,22 @@ func InitConfigFromDir(dir string) error {
 	// Add default config
 	j, err := json.Marshal(defaultConfig)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	reader := bytes.NewReader(j)
 	err = v.MergeConfig(reader)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	origins := map[string]string{}
+	for _, key := range ConfigKeys() {
+		origins[key] = OriginDefault
 	}
 
 	// Process config in system folder
-	var configFileDirs []string
+	var configFiles []string
 
 	// https://pureinfotech.com/list-environment-variables-windows-10/
 	// https://docs.microsoft.com/en-us/windows/deployment/usmt/usmt-recognized-environment-variables
@@ -98,70 +133,156 @@ func InitConfigFromDir(dir string) error {
 	if runtime.GOOS == "windows" {
 		appDataDir := os.Getenv("LOCALAPPDATA")
 		if len(appDataDir) > 0 {
-			configFileDirs = append(configFileDirs, appDataDir)
+			configFiles = append(configFiles, filepath.Join(appDataDir, ConfigFileName))
 		}
 	} else {
-		configFileDirs = append(configFileDirs, "/usr/local/etc/homectl")
+		configFiles = append(configFiles, filepath.Join("/usr/local/etc/homectl", ConfigFileName))
 	}
 
-	// Process config in user's HOME dir
+	// Process config in user's HOME dir, and in the XDG config dir
+	// https://specifications.freedesktop.org/basedir-spec/basedir-spec-latest.html
 	hd, err := homedir.Dir()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	configFileDirs = append(configFileDirs, path.Join(hd, ".homectl"))
+	configFiles = append(configFiles, filepath.Join(hd, ".homectl", ConfigFileName))
 
-	// Process config in the specified dir
-	configFileDirs = append(configFileDirs, dir)
+	xdgConfigHome := os.Getenv("XDG_CONFIG_HOME")
+	if xdgConfigHome == "" && runtime.GOOS != "windows" {
+		xdgConfigHome = filepath.Join(hd, ".config")
+	}
+	if xdgConfigHome != "" {
+		configFiles = append(configFiles, filepath.Join(xdgConfigHome, "homectl", ConfigFileName))
+	}
+
+	// Process the project config: '--config', 'HOMECTL_CONFIG', or 'homectl.yaml' in the specified dir or its parents
+	projectConfigFile, err := findProjectConfigFile(fss, dir, flags)
+	if err != nil {
+		return nil, err
+	}
+	if projectConfigFile != "" {
+		configFiles = append(configFiles, projectConfigFile)
+	}
+
+	loader := &configLoader{l: l, fss: fss, v: v, origins: origins}
 
-	for _, dir := range configFileDirs {
-		configFile := path.Join(dir, "homectl.yaml")
-		err = processConfigFile(configFile, v)
+	processed := map[string]bool{}
+	for _, configFile := range configFiles {
+		// The project config can be one of the system or user configs, e.g. when running in the HOME dir
+		if processed[configFile] {
+			continue
+		}
+		processed[configFile] = true
+
+		err = loader.processConfigFile(configFile)
 		if err != nil {
-			return err
+			return nil, err
+		}
+	}
+
+	// Process the profile, overlaying the config files
+	profile, profileOrigin := selectedProfile(flags)
+	if profile != "" {
+		if err = loader.applyProfile(profile); err != nil {
+			return nil, err
 		}
+		origins[profileKey] = profileOrigin
 	}
 
+	// Process ENV vars
+	if err = bindEnv(v, origins); err != nil {
+		return nil, err
+	}
+
+	// Process command-line args
+	applyFlags(v, flags, origins)
+
 	// https://gist.github.com/chazcheadle/45bf85b793dea2b71bd05ebaa3c28644
 	// https://sagikazarmark.hu/blog/decoding-custom-formats-with-viper/
-	err = v.Unmarshal(&Config)
+	c := &Config{
+		ProjectConfigFile: projectConfigFile,
+		Profile:           profile,
+		Origins:           origins,
+	}
+
+	err = v.Unmarshal(&c.Configuration, viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		mapstructure.StringToTimeDurationHookFunc(),
+		envDecodeHook(),
+	)))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	// The project root is the folder of the project config, or the specified dir if there is no project config
+	projectRoot := dir
+	if projectConfigFile != "" {
+		projectRoot = filepath.Dir(projectConfigFile)
+	}
+
+	c.BasePath = resolveBasePath(c.BasePath, origins["base_path"], projectRoot, dir)
+
+	return c, nil
 }
 
-// https://github.com/NCAR/go-figure
-// https://github.com/spf13/viper/issues/181
-// https://medium.com/@bnprashanth256/reading-configuration-files-and-environment-variables-in-go-golang-c2607f912b63
-func processConfigFile(path string, v *viper.Viper) error {
-	l := logger.Logger.With("config", path)
-	if !utils.FileExists(path) {
-		l.Debug("No CLI config found")
-		return nil
+// findProjectConfigFile returns the project config file from the '--config' flag or the 'HOMECTL_CONFIG' ENV var,
+// or finds 'homectl.yaml' in the dir or its parents. Returns "" if there is no project config
+func findProjectConfigFile(fss fs.FileSystem, dir string, flags *pflag.FlagSet) (string, error) {
+	configFile := os.Getenv(ConfigFileEnvName)
+	if flags != nil {
+		if flag := flags.Lookup(configFileFlag); flag != nil && flag.Changed {
+			configFile = flag.Value.String()
+		}
 	}
 
-	l.Debug("Found CLI config")
-
-	reader, err := os.Open(path)
-	if err != nil {
-		return err
+	if configFile != "" {
+		if !filepath.IsAbs(configFile) {
+			configFile = filepath.Join(dir, configFile)
+		}
+		if !fss.FileExists(configFile) {
+			return "", fmt.Errorf("the config file '%s' does not exist", configFile)
+		}
+		return filepath.Clean(configFile), nil
 	}
 
-	defer func(reader *os.File) {
-		err := reader.Close()
-		if err != nil {
-			l.Error("Error closing file", err)
+	for current := filepath.Clean(dir); ; current = filepath.Dir(current) {
+		configFile = filepath.Join(current, ConfigFileName)
+		if fss.FileExists(configFile) {
+			return configFile, nil
+		}
+		if filepath.Dir(current) == current {
+			return "", nil
 		}
-	}(reader)
+	}
+}
 
-	err = v.MergeConfig(reader)
-	if err != nil {
-		return err
+// resolveBasePath returns the absolute 'base_path'. The relative 'base_path' is resolved against the folder of the config file
+// that set it, or against the dir if it's set by the ENV var or the flag. The default 'base_path' is the project root
+func resolveBasePath(basePath string, origin string, projectRoot string, dir string) string {
+	if filepath.IsAbs(basePath) {
+		return filepath.Clean(basePath)
 	}
 
-	l.Debug("Processed CLI config")
+	switch {
+	case strings.HasPrefix(origin, OriginFile):
+		// The origin of the keys set by the profiles is 'file:<path>#profiles.<name>'
+		file := strings.SplitN(strings.TrimPrefix(origin, OriginFile), "#", 2)[0]
+		return filepath.Join(filepath.Dir(file), basePath)
+	case strings.HasPrefix(origin, OriginEnv), strings.HasPrefix(origin, OriginFlag):
+		return filepath.Join(dir, basePath)
+	}
+
+	return filepath.Join(projectRoot, basePath)
+}
 
-	return nil
+// selectedProfile returns the profile from the '--profile' flag or the 'HOMECTL_PROFILE' ENV var, and its origin
+func selectedProfile(flags *pflag.FlagSet) (string, string) {
+	if flags != nil {
+		if flag := flags.Lookup(profileFlag); flag != nil && flag.Changed {
+			return flag.Value.String(), OriginFlag + profileFlag
+		}
+	}
+	if profile := os.Getenv(ProfileEnvName); profile != "" {
+		return profile, OriginEnv + ProfileEnvName
+	}
+	return "", ""
 }
@@ -0,0 +1,96 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/home-sol/homectl/pkg/config"
+	"github.com/home-sol/homectl/pkg/fs"
+)
+
+// newTestConfigFs returns the in-memory file system with the config files
+func newTestConfigFs(t *testing.T, files map[string]string) fs.FileSystem {
+	fss := fs.NewMemFileSystem()
+	for name, content := range files {
+		require.NoError(t, fss.WriteFile(name, []byte(content), 0644))
+	}
+	return fss
+}
+
+// loadTestConfig loads the CLI config for the dir from the file system
+func loadTestConfig(t *testing.T, fss fs.FileSystem, dir string, flags *pflag.FlagSet) *config.Config {
+	cfg, err := config.Load(fss, dir, flags, zap.NewNop().Sugar())
+	require.NoError(t, err)
+	return cfg
+}
+
+func TestConfigProjectDiscovery(t *testing.T) {
+	fss := newTestConfigFs(t, map[string]string{
+		"repo/homectl.yaml":                    "base_path: \"infra\"\nstacks:\n  base_path: \"repo-stacks\"\n",
+		"repo/other.yaml":                      "stacks:\n  base_path: \"other-stacks\"\n",
+		"xdg/homectl/homectl.yaml":             "workflows:\n  base_path: \"xdg-workflows\"\n",
+		"repo/components/terraform/vpc/x.yaml": "",
+	})
+	t.Setenv("XDG_CONFIG_HOME", "/xdg")
+	t.Setenv(config.ConfigFileEnvName, "")
+
+	t.Run("parent", func(t *testing.T) {
+		// The project config is found in the parent folder, and 'base_path' is relative to it
+		cfg := loadTestConfig(t, fss, "/repo/components/terraform/vpc", nil)
+		assert.Equal(t, "/repo/homectl.yaml", cfg.ProjectConfigFile)
+		assert.Equal(t, "/repo/infra", cfg.BasePath)
+		assert.Equal(t, "repo-stacks", cfg.Stacks.BasePath)
+		assert.Equal(t, "xdg-workflows", cfg.Workflows.BasePath)
+	})
+
+	t.Run("env", func(t *testing.T) {
+		t.Setenv(config.ConfigFileEnvName, "other.yaml")
+
+		cfg := loadTestConfig(t, fss, "/repo", nil)
+		assert.Equal(t, "/repo/other.yaml", cfg.ProjectConfigFile)
+		assert.Equal(t, "other-stacks", cfg.Stacks.BasePath)
+		// The default 'base_path' is the folder of the project config
+		assert.Equal(t, "/repo", cfg.BasePath)
+	})
+
+	t.Run("flag", func(t *testing.T) {
+		t.Setenv(config.ConfigFileEnvName, "/repo/homectl.yaml")
+
+		flags := pflag.NewFlagSet("homectl", pflag.ContinueOnError)
+		config.AddFlags(flags)
+		require.NoError(t, flags.Parse([]string{"--config", "/repo/other.yaml"}))
+
+		// '--config' overrides 'HOMECTL_CONFIG'
+		cfg := loadTestConfig(t, fss, "/", flags)
+		assert.Equal(t, "/repo/other.yaml", cfg.ProjectConfigFile)
+		assert.Equal(t, "other-stacks", cfg.Stacks.BasePath)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		t.Setenv(config.ConfigFileEnvName, "/repo/missing.yaml")
+
+		_, err := config.Load(fss, "/repo", nil, zap.NewNop().Sugar())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "the config file '/repo/missing.yaml' does not exist")
+	})
+
+	t.Run("none", func(t *testing.T) {
+		// Without the project config, the defaults are relative to the specified dir
+		cfg := loadTestConfig(t, fss, "/work/dir", nil)
+		assert.Equal(t, "", cfg.ProjectConfigFile)
+		assert.Equal(t, "/work/dir", cfg.BasePath)
+		assert.Equal(t, "stacks", cfg.Stacks.BasePath)
+	})
+
+	t.Run("base path env", func(t *testing.T) {
+		// 'base_path' from the ENV var is relative to the specified dir
+		t.Setenv("HOMECTL_BASE_PATH", "../shared")
+
+		cfg := loadTestConfig(t, fss, "/repo/components", nil)
+		assert.Equal(t, "/repo/shared", cfg.BasePath)
+	})
+}
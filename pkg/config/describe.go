@@ -0,0 +1,135 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+const (
+	// OriginDefault is the origin of the config keys that are not set in the config files, ENV vars and flags
+	OriginDefault = "default"
+	// OriginFile is the prefix of the origin of the config keys set in the config file
+	OriginFile = "file:"
+	// OriginEnv is the prefix of the origin of the config keys set by the ENV var
+	OriginEnv = "env:"
+	// OriginFlag is the prefix of the origin of the config keys set by the command-line flag
+	OriginFlag = "flag:--"
+
+	// profileKey is the key of the active profile in 'Settings', 'Describe' and 'Origins'
+	profileKey = "profile"
+
+	// redacted replaces the values of the secret config keys in 'Settings' and 'Describe'
+	redacted = "<redacted>"
+)
+
+// secretKeys are the config keys with the credentials, which are not printed
+var secretKeys = map[string]bool{
+	"vendor.terraform_registry.token": true,
+}
+
+// Setting is the config key with its effective value and the source that set it
+type Setting struct {
+	Key    string      `yaml:"key" json:"key"`
+	Value  interface{} `yaml:"value" json:"value"`
+	Origin string      `yaml:"origin" json:"origin"`
+}
+
+// Settings returns the effective values of all config keys with their origins, in the order of the config fields.
+// The active profile is the first setting
+func (c *Config) Settings() []Setting {
+	var settings []Setting
+
+	if c.Profile != "" {
+		settings = append(settings, Setting{Key: profileKey, Value: c.Profile, Origin: c.Origins[profileKey]})
+	}
+
+	values := configValues(reflect.ValueOf(c.Configuration), "")
+	for _, key := range ConfigKeys() {
+		origin, ok := c.Origins[key]
+		if !ok {
+			origin = OriginDefault
+		}
+		settings = append(settings, Setting{Key: key, Value: values[key], Origin: origin})
+	}
+
+	return settings
+}
+
+// Describe returns the effective config as the nested maps of the config keys, with the active profile as 'profile'
+func (c *Config) Describe() map[string]interface{} {
+	result := map[string]interface{}{}
+
+	if c.Profile != "" {
+		result[profileKey] = c.Profile
+	}
+
+	for key, value := range configValues(reflect.ValueOf(c.Configuration), "") {
+		parts := strings.Split(key, ".")
+		m := result
+		for _, part := range parts[:len(parts)-1] {
+			if _, ok := m[part]; !ok {
+				m[part] = map[string]interface{}{}
+			}
+			m = m[part].(map[string]interface{})
+		}
+		m[parts[len(parts)-1]] = value
+	}
+
+	return result
+}
+
+// configValues returns the values of the config fields by the config keys (see 'ConfigKeys')
+func configValues(v reflect.Value, prefix string) map[string]interface{} {
+	values := map[string]interface{}{}
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		name := strings.Split(field.Tag.Get("mapstructure"), ",")[0]
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+
+		key := prefix + name
+		if field.Type.Kind() == reflect.Struct {
+			for k, value := range configValues(v.Field(i), key+".") {
+				values[k] = value
+			}
+			continue
+		}
+
+		values[key] = configValue(v.Field(i))
+		if secretKeys[key] && !v.Field(i).IsZero() {
+			values[key] = redacted
+		}
+	}
+
+	return values
+}
+
+// configValue returns the value in the format of the config file: the durations as strings (e.g. '30s'), and the empty lists
+// and maps instead of nil
+func configValue(v reflect.Value) interface{} {
+	switch {
+	case v.Type() == reflect.TypeOf(time.Duration(0)):
+		return v.Interface().(time.Duration).String()
+	case v.Kind() == reflect.Slice && v.IsNil():
+		return reflect.MakeSlice(v.Type(), 0, 0).Interface()
+	case v.Kind() == reflect.Map && v.IsNil():
+		return reflect.MakeMap(v.Type()).Interface()
+	}
+	return v.Interface()
+}
+
+// findConfigKey returns the config key of the key read from the config file. The keys inside the map fields
+// (e.g. 'vendor.aliases.<name>') belong to the map field. The unknown keys return ""
+func findConfigKey(key string) string {
+	for _, configKey := range ConfigKeys() {
+		if key == configKey || strings.HasPrefix(key, configKey+".") {
+			return configKey
+		}
+	}
+	return ""
+}
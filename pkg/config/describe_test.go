@@ -0,0 +1,73 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/home-sol/homectl/pkg/config"
+)
+
+func TestConfigSettings(t *testing.T) {
+	t.Setenv("HOMECTL_STACKS_NAME_PATTERN", "{tenant}-{stage}")
+
+	flags := pflag.NewFlagSet("homectl", pflag.ContinueOnError)
+	config.AddFlags(flags)
+	require.NoError(t, flags.Parse([]string{"--workflows-dir", "flows"}))
+
+	cfg, err := initTestConfig(t, `
+stacks:
+  base_path: "stacks"
+  name_pattern: "{tenant}-{environment}-{stage}"
+vendor:
+  terraform_registry:
+    token: "secret"
+  aliases:
+    cp: "github.com/cloudposse/{{.Path}}"
+`, flags)
+	require.NoError(t, err)
+
+	settings := map[string]config.Setting{}
+	for _, setting := range cfg.Settings() {
+		settings[setting.Key] = setting
+	}
+	assert.Len(t, settings, len(config.ConfigKeys()))
+
+	for key, expected := range map[string]config.Setting{
+		"components.terraform.base_path": {Value: "components/terraform", Origin: "default"},
+		"stacks.base_path":               {Value: "stacks", Origin: "file:/project/homectl.yaml"},
+		"stacks.name_pattern":            {Value: "{tenant}-{stage}", Origin: "env:HOMECTL_STACKS_NAME_PATTERN"},
+		"workflows.base_path":            {Value: "flows", Origin: "flag:--workflows-dir"},
+		"vendor.http.no_proxy":           {Value: []string{}, Origin: "default"},
+		"vendor.lock.timeout":            {Value: "30s", Origin: "default"},
+		// The keys inside the maps belong to the map field
+		"vendor.aliases": {Value: map[string]string{"cp": "github.com/cloudposse/{{.Path}}"}, Origin: "file:/project/homectl.yaml"},
+		// The credentials are not printed
+		"vendor.terraform_registry.token": {Value: "<redacted>", Origin: "file:/project/homectl.yaml"},
+	} {
+		expected.Key = key
+		assert.Equal(t, expected, settings[key], key)
+	}
+}
+
+func TestConfigDescribe(t *testing.T) {
+	cfg, err := initTestConfig(t, `
+components:
+  terraform:
+    base_path: "infra"
+`, nil)
+	require.NoError(t, err)
+
+	described := cfg.Describe()
+
+	components := described["components"].(map[string]interface{})
+	terraform := components["terraform"].(map[string]interface{})
+	assert.Equal(t, "infra", terraform["base_path"])
+	assert.Equal(t, true, terraform["deploy_run_init"])
+
+	vendor := described["vendor"].(map[string]interface{})
+	assert.Equal(t, "", vendor["terraform_registry"].(map[string]interface{})["token"])
+	assert.Equal(t, "registry.terraform.io", vendor["terraform_registry"].(map[string]interface{})["host"])
+}
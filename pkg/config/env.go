@@ -0,0 +1,102 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+)
+
+// EnvPrefix is the prefix of the ENV vars overriding the config keys, e.g. 'HOMECTL_COMPONENTS_TERRAFORM_BASE_PATH'
+// for 'components.terraform.base_path'
+const EnvPrefix = "HOMECTL"
+
+// EnvName returns the name of the ENV var overriding the config key
+func EnvName(key string) string {
+	return EnvPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// ConfigKeys returns the keys of all fields of the CLI config, e.g. 'components.terraform.base_path'
+func ConfigKeys() []string {
+	return configKeys(reflect.TypeOf(Configuration{}), "")
+}
+
+func configKeys(t reflect.Type, prefix string) []string {
+	var keys []string
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		// The fields without the 'mapstructure' tag are matched by the lowercase field name
+		name := strings.Split(field.Tag.Get("mapstructure"), ",")[0]
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+
+		key := prefix + name
+		if field.Type.Kind() == reflect.Struct {
+			keys = append(keys, configKeys(field.Type, key+".")...)
+			continue
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// bindEnv binds all config keys to the 'HOMECTL_*' ENV vars, so they override the config files
+func bindEnv(v *viper.Viper, origins map[string]string) error {
+	for _, key := range ConfigKeys() {
+		name := EnvName(key)
+		if err := v.BindEnv(key, name); err != nil {
+			return err
+		}
+		// The empty ENV vars are ignored
+		if os.Getenv(name) != "" {
+			origins[key] = OriginEnv + name
+		}
+	}
+	return nil
+}
+
+// envDecodeHook decodes the ENV var values of the list fields from comma-separated values (e.g. 'a, b'),
+// and of the map fields from comma-separated 'name=value' pairs
+func envDecodeHook() mapstructure.DecodeHookFunc {
+	return func(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+		if from.Kind() != reflect.String {
+			return data, nil
+		}
+
+		s := strings.TrimSpace(data.(string))
+
+		switch to.Kind() {
+		case reflect.Slice:
+			values := []string{}
+			for _, value := range strings.Split(s, ",") {
+				if value = strings.TrimSpace(value); value != "" {
+					values = append(values, value)
+				}
+			}
+			return values, nil
+		case reflect.Map:
+			values := map[string]string{}
+			for _, pair := range strings.Split(s, ",") {
+				if pair = strings.TrimSpace(pair); pair == "" {
+					continue
+				}
+				parts := strings.SplitN(pair, "=", 2)
+				if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+					return nil, fmt.Errorf("invalid value '%s', expected comma-separated 'name=value' pairs", s)
+				}
+				values[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+			}
+			return values, nil
+		}
+
+		return data, nil
+	}
+}
@@ -0,0 +1,91 @@
+package config_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/home-sol/homectl/pkg/config"
+	"github.com/home-sol/homectl/pkg/fs"
+)
+
+// initTestConfig loads the CLI config from the 'homectl.yaml' file in the in-memory file system and the flags
+func initTestConfig(t *testing.T, content string, flags *pflag.FlagSet) (*config.Config, error) {
+	fss := fs.NewMemFileSystem()
+	require.NoError(t, fss.WriteFile("project/homectl.yaml", []byte(content), 0644))
+
+	return config.Load(fss, "/project", flags, zap.NewNop().Sugar())
+}
+
+func TestConfigEnv(t *testing.T) {
+	t.Setenv("HOMECTL_COMPONENTS_TERRAFORM_BASE_PATH", "infra/terraform")
+	t.Setenv("HOMECTL_COMPONENTS_TERRAFORM_APPLY_AUTO_APPROVE", "true")
+	t.Setenv("HOMECTL_STACKS_INCLUDED_PATHS", "orgs/**/*, catalog/*.yaml,")
+	t.Setenv("HOMECTL_VENDOR_GIT_CACHE_DIR", "/tmp/homectl-git")
+	t.Setenv("HOMECTL_VENDOR_POLICY_MAX_FILE_COUNT", "100")
+	t.Setenv("HOMECTL_VENDOR_LOCK_TIMEOUT", "2m")
+	t.Setenv("HOMECTL_VENDOR_ALIASES", "cp=github.com/cloudposse/{{.Path}}?ref={{.Version}}, local=./modules/{{.Path}}")
+
+	cfg, err := initTestConfig(t, `
+components:
+  terraform:
+    base_path: "components/terraform"
+    deploy_run_init: false
+stacks:
+  base_path: "stacks"
+  included_paths:
+    - "**/*"
+`, nil)
+	require.NoError(t, err)
+
+	// The ENV vars override the config file
+	assert.Equal(t, "infra/terraform", cfg.Components.Terraform.BasePath)
+	assert.True(t, cfg.Components.Terraform.ApplyAutoApprove)
+	assert.Equal(t, []string{"orgs/**/*", "catalog/*.yaml"}, cfg.Stacks.IncludedPaths)
+
+	// The keys that are not set in the config file are overridden too
+	assert.Equal(t, "/tmp/homectl-git", cfg.Vendor.Git.CacheDir)
+	assert.Equal(t, 100, cfg.Vendor.Policy.MaxFileCount)
+	assert.Equal(t, 2*time.Minute, cfg.Vendor.Lock.Timeout)
+	assert.Equal(t, map[string]string{
+		"cp":    "github.com/cloudposse/{{.Path}}?ref={{.Version}}",
+		"local": "./modules/{{.Path}}",
+	}, cfg.Vendor.Aliases)
+
+	// The keys without the ENV vars keep the values from the config file and the defaults
+	assert.False(t, cfg.Components.Terraform.DeployRunInit)
+	assert.Equal(t, "stacks", cfg.Stacks.BasePath)
+	assert.Equal(t, "components/helmfile", cfg.Components.Helmfile.BasePath)
+}
+
+func TestConfigEnvInvalid(t *testing.T) {
+	t.Setenv("HOMECTL_VENDOR_ALIASES", "cp")
+
+	_, err := initTestConfig(t, "", nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "expected comma-separated 'name=value' pairs")
+}
+
+func TestConfigKeys(t *testing.T) {
+	keys := config.ConfigKeys()
+
+	for _, key := range []string{
+		"base_path",
+		"components.terraform.base_path",
+		"components.helmfile.kubeconfig_path",
+		"stacks.excluded_paths",
+		"logs.verbose",
+		"vendor.terraform_registry.token",
+		"vendor.http.ca_bundles",
+		"vendor.oci.insecure_registries",
+		"vendor.aliases",
+	} {
+		assert.Contains(t, keys, key)
+	}
+
+	assert.Equal(t, "HOMECTL_COMPONENTS_TERRAFORM_BASE_PATH", config.EnvName("components.terraform.base_path"))
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// configFlag is the global CLI flag overriding the config key
+type configFlag struct {
+	name   string
+	key    string
+	isBool bool
+	usage  string
+}
+
+// configFlags are the global CLI flags overriding the config keys. The flags are applied in this order,
+// so '--stacks-dir' overrides '--config-dir'
+var configFlags = []configFlag{
+	{name: "terraform-dir", key: "components.terraform.base_path", usage: "Terraform components folder"},
+	{name: "helmfile-dir", key: "components.helmfile.base_path", usage: "Helmfile components folder"},
+	{name: "config-dir", key: "stacks.base_path", usage: "Stacks config folder"},
+	{name: "stacks-dir", key: "stacks.base_path", usage: "Stacks config folder"},
+	{name: "workflows-dir", key: "workflows.base_path", usage: "Workflows folder"},
+	{name: "deploy-run-init", key: "components.terraform.deploy_run_init", isBool: true, usage: "Run 'terraform init' before 'terraform deploy'"},
+	{name: "init-run-reconfigure", key: "components.terraform.init_run_reconfigure", isBool: true, usage: "Run 'terraform init' with '-reconfigure'"},
+	{name: "auto-generate-backend-file", key: "components.terraform.auto_generate_backend_file", isBool: true, usage: "Generate the Terraform backend config file"},
+}
+
+const (
+	// configFileFlag is the global CLI flag with the path of the project config file
+	configFileFlag = "config"
+	// profileFlag is the global CLI flag with the name of the active profile
+	profileFlag = "profile"
+)
+
+// AddFlags adds the global CLI flags overriding the config keys, and the '--config' and '--profile' flags, to the flag set
+func AddFlags(flags *pflag.FlagSet) {
+	flags.String(configFileFlag, "", "Project config file, instead of '"+ConfigFileName+"' in the current folder or its parents, can also be set using '"+ConfigFileEnvName+"' ENV var")
+	flags.String(profileFlag, "", "Profile from 'profiles' in the config files overlaying the config, can also be set using '"+ProfileEnvName+"' ENV var")
+
+	for _, flag := range configFlags {
+		usage := flag.usage + ", overrides '" + flag.key + "'"
+		if flag.isBool {
+			flags.Bool(flag.name, false, usage)
+		} else {
+			flags.String(flag.name, "", usage)
+		}
+	}
+}
+
+// applyFlags overrides the config keys with the global CLI flags that are set in the command line.
+// The flags that are not set don't override the config files and the ENV vars
+func applyFlags(v *viper.Viper, flags *pflag.FlagSet, origins map[string]string) {
+	if flags == nil {
+		return
+	}
+
+	for _, configFlag := range configFlags {
+		flag := flags.Lookup(configFlag.name)
+		if flag == nil || !flag.Changed {
+			continue
+		}
+		v.Set(configFlag.key, flag.Value.String())
+		origins[configFlag.key] = OriginFlag + configFlag.name
+	}
+}
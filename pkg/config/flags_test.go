@@ -0,0 +1,47 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/home-sol/homectl/pkg/config"
+)
+
+func TestConfigFlags(t *testing.T) {
+	t.Setenv("HOMECTL_COMPONENTS_TERRAFORM_BASE_PATH", "env/terraform")
+	t.Setenv("HOMECTL_COMPONENTS_HELMFILE_BASE_PATH", "env/helmfile")
+
+	flags := pflag.NewFlagSet("homectl", pflag.ContinueOnError)
+	config.AddFlags(flags)
+	require.NoError(t, flags.Parse([]string{
+		"--terraform-dir", "flag/terraform",
+		"--config-dir", "flag/config",
+		"--stacks-dir", "flag/stacks",
+		"--deploy-run-init=false",
+		"--auto-generate-backend-file",
+	}))
+
+	cfg, err := initTestConfig(t, `
+components:
+  terraform:
+    base_path: "components/terraform"
+    init_run_reconfigure: false
+workflows:
+  base_path: "file/workflows"
+`, flags)
+	require.NoError(t, err)
+
+	// The flags override the ENV vars and the config file
+	assert.Equal(t, "flag/terraform", cfg.Components.Terraform.BasePath)
+	assert.Equal(t, "flag/stacks", cfg.Stacks.BasePath)
+	assert.False(t, cfg.Components.Terraform.DeployRunInit)
+	assert.True(t, cfg.Components.Terraform.AutoGenerateBackendFile)
+
+	// The flags that are not set don't override anything
+	assert.Equal(t, "env/helmfile", cfg.Components.Helmfile.BasePath)
+	assert.Equal(t, "file/workflows", cfg.Workflows.BasePath)
+	assert.False(t, cfg.Components.Terraform.InitRunReconfigure)
+}
@@ -0,0 +1,229 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/home-sol/homectl/pkg/fs"
+)
+
+const (
+	// importKey is the list of the config files or globs imported by the config file, relative to its folder
+	importKey = "import"
+	// profilesKey is the map of the named profiles overlaying the config, selected with '--profile' or 'HOMECTL_PROFILE'
+	profilesKey = "profiles"
+)
+
+// configLoader merges the config files with their imports, and collects the profiles defined in them
+type configLoader struct {
+	l       *zap.SugaredLogger
+	fss     fs.FileSystem
+	v       *viper.Viper
+	origins map[string]string
+	// The profiles in the order of the processed files
+	profiles []configProfile
+}
+
+// configProfile is the profile overlay defined in the config file
+type configProfile struct {
+	name     string
+	file     string
+	settings map[string]interface{}
+}
+
+// processConfigFile merges the config file into the config, after the files it imports.
+// The missing config file is skipped
+// https://github.com/NCAR/go-figure
+// https://github.com/spf13/viper/issues/181
+// https://medium.com/@bnprashanth256/reading-configuration-files-and-environment-variables-in-go-golang-c2607f912b63
+func (c *configLoader) processConfigFile(path string) error {
+	l := c.l.With("config", path)
+	if !c.fss.FileExists(path) {
+		l.Debug("No CLI config found")
+		return nil
+	}
+
+	l.Debug("Found CLI config")
+
+	if err := c.mergeConfigFile(path, nil); err != nil {
+		return err
+	}
+
+	l.Debug("Processed CLI config")
+
+	return nil
+}
+
+// mergeConfigFile merges the files imported by the config file in order, then the config file itself.
+// 'importedBy' are the files importing the config file, used to detect the import cycles
+func (c *configLoader) mergeConfigFile(path string, importedBy []string) error {
+	for _, file := range importedBy {
+		if file == path {
+			return fmt.Errorf("import cycle in the config files: %s -> %s", strings.Join(importedBy, " -> "), path)
+		}
+	}
+
+	content, err := c.fss.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	fv := viper.New()
+	fv.SetConfigType("yaml")
+	if err = fv.ReadConfig(bytes.NewReader(content)); err != nil {
+		return fmt.Errorf("invalid config file '%s': %w", path, err)
+	}
+
+	for _, pattern := range fv.GetStringSlice(importKey) {
+		files, err := c.findImports(filepath.Dir(path), pattern)
+		if err != nil {
+			return fmt.Errorf("invalid 'import' '%s' in the config file '%s': %w", pattern, path, err)
+		}
+
+		for _, file := range files {
+			if err = c.mergeConfigFile(file, append(importedBy, path)); err != nil {
+				return err
+			}
+		}
+	}
+
+	profiles := fv.GetStringMap(profilesKey)
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		settings, ok := profiles[name].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("invalid profile '%s' in the config file '%s', it must be a map of the config keys", name, path)
+		}
+		c.profiles = append(c.profiles, configProfile{name: name, file: path, settings: settings})
+	}
+
+	settings := fv.AllSettings()
+	delete(settings, importKey)
+	delete(settings, profilesKey)
+
+	return c.merge(settings, OriginFile+path)
+}
+
+// findImports returns the imported config files. The globs (e.g. 'sites/*.yaml' or 'shared/**/*.yaml') are expanded
+// in the lexical order, and may match no files. The files without globs must exist
+func (c *configLoader) findImports(dir string, pattern string) ([]string, error) {
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(dir, pattern)
+	}
+	pattern = filepath.Clean(pattern)
+
+	if !strings.ContainsAny(pattern, "*?[{") {
+		if !c.fss.FileExists(pattern) {
+			return nil, fmt.Errorf("the config file '%s' does not exist", pattern)
+		}
+		return []string{pattern}, nil
+	}
+
+	if !doublestar.ValidatePattern(filepath.ToSlash(pattern)) {
+		return nil, doublestar.ErrBadPattern
+	}
+
+	// The files are listed from the folder before the first glob
+	root := pattern[:strings.IndexAny(pattern, "*?[{")]
+	root = root[:strings.LastIndex(root, string(filepath.Separator))+1]
+
+	var files []string
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		entries, err := c.fss.ReadDir(dir)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			name := filepath.Join(dir, entry.Name())
+			if entry.IsDir() {
+				if err = walk(name); err != nil {
+					return err
+				}
+				continue
+			}
+			if ok, _ := doublestar.Match(filepath.ToSlash(pattern), filepath.ToSlash(name)); ok {
+				files = append(files, name)
+			}
+		}
+		return nil
+	}
+
+	if err := walk(root); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// applyProfile merges the overlays of the profile from all config files in order. The profile names are case-insensitive
+func (c *configLoader) applyProfile(profile string) error {
+	found := false
+
+	for _, p := range c.profiles {
+		if p.name != strings.ToLower(profile) {
+			continue
+		}
+		found = true
+
+		if err := c.merge(p.settings, OriginFile+p.file+"#"+profilesKey+"."+p.name); err != nil {
+			return err
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("the profile '%s' is not defined in the config files, the defined profiles are: %s", profile, c.profileNames())
+	}
+
+	return nil
+}
+
+func (c *configLoader) profileNames() string {
+	var names []string
+	seen := map[string]bool{}
+	for _, p := range c.profiles {
+		if !seen[p.name] {
+			names = append(names, "'"+p.name+"'")
+			seen[p.name] = true
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
+}
+
+// merge deep-merges the settings into the config, and records the origin of the config keys they set
+func (c *configLoader) merge(settings map[string]interface{}, origin string) error {
+	// The keys are flattened by viper, e.g. 'components.terraform.base_path'
+	sv := viper.New()
+	if err := sv.MergeConfigMap(settings); err != nil {
+		return err
+	}
+
+	for _, key := range sv.AllKeys() {
+		if configKey := findConfigKey(key); configKey != "" {
+			c.origins[configKey] = origin
+		}
+	}
+
+	return c.v.MergeConfigMap(settings)
+}
@@ -0,0 +1,163 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/home-sol/homectl/pkg/config"
+)
+
+func TestConfigImport(t *testing.T) {
+	t.Setenv(config.ConfigFileEnvName, "")
+	t.Setenv(config.ProfileEnvName, "")
+
+	fss := newTestConfigFs(t, map[string]string{
+		"repo/homectl.yaml": `
+import:
+  - shared/base.yaml
+  - sites/*.yaml
+stacks:
+  name_pattern: "{tenant}-{stage}"
+`,
+		"repo/shared/base.yaml": `
+import:
+  - ../defaults/**/*.yaml
+stacks:
+  base_path: "base-stacks"
+  name_pattern: "{tenant}-{environment}-{stage}"
+workflows:
+  base_path: "base-workflows"
+`,
+		"repo/defaults/logs/logs.yaml": "logs:\n  verbose: true\n",
+		"repo/sites/a.yaml":            "workflows:\n  base_path: \"a-workflows\"\n",
+		"repo/sites/b.yaml":            "workflows:\n  base_path: \"b-workflows\"\n",
+		"repo/sites/readme.md":         "not a config\n",
+	})
+
+	cfg := loadTestConfig(t, fss, "/repo", nil)
+
+	// The imports are merged in order, and the importing file overrides them
+	assert.Equal(t, "{tenant}-{stage}", cfg.Stacks.NamePattern)
+	assert.Equal(t, "base-stacks", cfg.Stacks.BasePath)
+	assert.Equal(t, "b-workflows", cfg.Workflows.BasePath)
+	assert.True(t, cfg.Logs.Verbose)
+
+	assert.Equal(t, "file:/repo/sites/b.yaml", cfg.Origins["workflows.base_path"])
+	assert.Equal(t, "file:/repo/homectl.yaml", cfg.Origins["stacks.name_pattern"])
+}
+
+func TestConfigImportInvalid(t *testing.T) {
+	t.Setenv(config.ConfigFileEnvName, "")
+	t.Setenv(config.ProfileEnvName, "")
+
+	for name, tc := range map[string]struct {
+		files map[string]string
+		err   string
+	}{
+		"missing": {
+			files: map[string]string{"repo/homectl.yaml": "import:\n  - missing.yaml\n"},
+			err:   "invalid 'import' 'missing.yaml' in the config file '/repo/homectl.yaml': the config file '/repo/missing.yaml' does not exist",
+		},
+		"cycle": {
+			files: map[string]string{
+				"repo/homectl.yaml": "import:\n  - a.yaml\n",
+				"repo/a.yaml":       "import:\n  - homectl.yaml\n",
+			},
+			err: "import cycle in the config files: /repo/homectl.yaml -> /repo/a.yaml -> /repo/homectl.yaml",
+		},
+		"glob": {
+			files: map[string]string{"repo/homectl.yaml": "import:\n  - \"sites/[a.yaml\"\n"},
+			err:   "syntax error in pattern",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			fss := newTestConfigFs(t, tc.files)
+
+			_, err := config.Load(fss, "/repo", nil, zap.NewNop().Sugar())
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.err)
+		})
+	}
+}
+
+func TestConfigProfiles(t *testing.T) {
+	t.Setenv(config.ConfigFileEnvName, "")
+	t.Setenv(config.ProfileEnvName, "")
+
+	fss := newTestConfigFs(t, map[string]string{
+		"repo/homectl.yaml": `
+import:
+  - sites.yaml
+stacks:
+  base_path: "stacks"
+profiles:
+  cabin:
+    stacks:
+      base_path: "cabin-stacks"
+`,
+		"repo/sites.yaml": `
+workflows:
+  base_path: "workflows"
+profiles:
+  cabin:
+    stacks:
+      base_path: "imported-cabin-stacks"
+    workflows:
+      base_path: "cabin-workflows"
+  town:
+    base_path: "town"
+`,
+	})
+
+	t.Run("none", func(t *testing.T) {
+		cfg := loadTestConfig(t, fss, "/repo", nil)
+		assert.Equal(t, "", cfg.Profile)
+		assert.Equal(t, "stacks", cfg.Stacks.BasePath)
+		assert.NotContains(t, cfg.Describe(), "profile")
+	})
+
+	t.Run("env", func(t *testing.T) {
+		t.Setenv(config.ProfileEnvName, "cabin")
+
+		cfg := loadTestConfig(t, fss, "/repo", nil)
+		assert.Equal(t, "cabin", cfg.Profile)
+
+		// The profile overlays of the importing file override the imported ones
+		assert.Equal(t, "cabin-stacks", cfg.Stacks.BasePath)
+		assert.Equal(t, "cabin-workflows", cfg.Workflows.BasePath)
+		assert.Equal(t, "file:/repo/sites.yaml#profiles.cabin", cfg.Origins["workflows.base_path"])
+
+		assert.Equal(t, "cabin", cfg.Describe()["profile"])
+		assert.Equal(t, config.Setting{Key: "profile", Value: "cabin", Origin: "env:HOMECTL_PROFILE"}, cfg.Settings()[0])
+	})
+
+	t.Run("flag", func(t *testing.T) {
+		t.Setenv(config.ProfileEnvName, "cabin")
+
+		flags := pflag.NewFlagSet("homectl", pflag.ContinueOnError)
+		config.AddFlags(flags)
+		require.NoError(t, flags.Parse([]string{"--profile", "town", "--workflows-dir", "flag-workflows"}))
+
+		cfg := loadTestConfig(t, fss, "/repo", flags)
+		assert.Equal(t, "town", cfg.Profile)
+
+		// 'base_path' set by the profile is relative to the file defining the profile
+		assert.Equal(t, "/repo/town", cfg.BasePath)
+		assert.Equal(t, "stacks", cfg.Stacks.BasePath)
+
+		// The flags override the profile
+		assert.Equal(t, "flag-workflows", cfg.Workflows.BasePath)
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		t.Setenv(config.ProfileEnvName, "beach")
+
+		_, err := config.Load(fss, "/repo", nil, zap.NewNop().Sugar())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "the profile 'beach' is not defined in the config files, the defined profiles are: 'cabin', 'town'")
+	})
+}
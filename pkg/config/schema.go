@@ -1,5 +1,7 @@
 package config
 
+import "time"
+
 type Terraform struct {
 	BasePath                string `yaml:"base_path" json:"base_path" mapstructure:"base_path"`
 	ApplyAutoApprove        bool   `yaml:"apply_auto_approve" json:"apply_auto_approve" mapstructure:"apply_auto_approve"`
@@ -36,10 +38,63 @@ type Logs struct {
 	Colors  bool `yaml:"colors" json:"colors" mapstructure:"colors"`
 }
 
+type TerraformRegistry struct {
+	Host  string `yaml:"host" json:"host" mapstructure:"host"`
+	Token string `yaml:"token" json:"token" mapstructure:"token"`
+}
+
+type Git struct {
+	CacheDir string `yaml:"cache_dir" json:"cache_dir" mapstructure:"cache_dir"`
+}
+
+type VendorPolicy struct {
+	AllowedSchemes     []string `yaml:"allowed_schemes" json:"allowed_schemes" mapstructure:"allowed_schemes"`
+	AllowedHosts       []string `yaml:"allowed_hosts" json:"allowed_hosts" mapstructure:"allowed_hosts"`
+	AllowedUriPrefixes []string `yaml:"allowed_uri_prefixes" json:"allowed_uri_prefixes" mapstructure:"allowed_uri_prefixes"`
+	DenyHttp           bool     `yaml:"deny_http" json:"deny_http" mapstructure:"deny_http"`
+	MaxArchiveSize     int64    `yaml:"max_archive_size" json:"max_archive_size" mapstructure:"max_archive_size"`
+	MaxFileCount       int      `yaml:"max_file_count" json:"max_file_count" mapstructure:"max_file_count"`
+}
+
+type SecretScan struct {
+	Enabled      bool     `yaml:"enabled" json:"enabled" mapstructure:"enabled"`
+	AllowedPaths []string `yaml:"allowed_paths" json:"allowed_paths" mapstructure:"allowed_paths"`
+}
+
+type Http struct {
+	Proxy      string   `yaml:"proxy" json:"proxy" mapstructure:"proxy"`
+	HttpsProxy string   `yaml:"https_proxy" json:"https_proxy" mapstructure:"https_proxy"`
+	NoProxy    []string `yaml:"no_proxy" json:"no_proxy" mapstructure:"no_proxy"`
+	CaBundles  []string `yaml:"ca_bundles" json:"ca_bundles" mapstructure:"ca_bundles"`
+	ClientCert string   `yaml:"client_cert" json:"client_cert" mapstructure:"client_cert"`
+	ClientKey  string   `yaml:"client_key" json:"client_key" mapstructure:"client_key"`
+}
+
+type Oci struct {
+	InsecureRegistries []string `yaml:"insecure_registries" json:"insecure_registries" mapstructure:"insecure_registries"`
+}
+
+type VendorLock struct {
+	Dir     string        `yaml:"dir" json:"dir" mapstructure:"dir"`
+	Timeout time.Duration `yaml:"timeout" json:"timeout" mapstructure:"timeout"`
+}
+
+type Vendor struct {
+	TerraformRegistry TerraformRegistry `yaml:"terraform_registry" json:"terraform_registry" mapstructure:"terraform_registry"`
+	Git               Git               `yaml:"git" json:"git" mapstructure:"git"`
+	Http              Http              `yaml:"http" json:"http" mapstructure:"http"`
+	Oci               Oci               `yaml:"oci" json:"oci" mapstructure:"oci"`
+	Policy            VendorPolicy      `yaml:"policy" json:"policy" mapstructure:"policy"`
+	SecretScan        SecretScan        `yaml:"secret_scan" json:"secret_scan" mapstructure:"secret_scan"`
+	Lock              VendorLock        `yaml:"lock" json:"lock" mapstructure:"lock"`
+	Aliases           map[string]string `yaml:"aliases" json:"aliases" mapstructure:"aliases"`
+}
+
 type Configuration struct {
 	BasePath   string `yaml:"base_path" json:"base_path" mapstructure:"base_path"`
 	Components Components
 	Stacks     Stacks
 	Workflows  Workflows
 	Logs       Logs
+	Vendor     Vendor `yaml:"vendor" json:"vendor" mapstructure:"vendor"`
 }
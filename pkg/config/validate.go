@@ -0,0 +1,274 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+
+	"github.com/home-sol/homectl/pkg/fs"
+)
+
+const (
+	// SeverityError is the severity of the config issues that fail 'config validate'
+	SeverityError = "error"
+	// SeverityWarning is the severity of the config issues that fail 'config validate' only with '--strict'
+	SeverityWarning = "warning"
+)
+
+// patternTokens are the '{token}' placeholders supported in the name patterns
+var patternTokens = []string{"namespace", "tenant", "environment", "stage"}
+
+var patternTokenRegexp = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// ValidationIssue is the problem found in the CLI config by 'Validate'
+type ValidationIssue struct {
+	Severity string `yaml:"severity" json:"severity"`
+	Key      string `yaml:"key" json:"key"`
+	Message  string `yaml:"message" json:"message"`
+	Origin   string `yaml:"origin" json:"origin"`
+}
+
+func (i ValidationIssue) String() string {
+	return fmt.Sprintf("%s: %s: %s (%s)", i.Severity, i.Key, i.Message, i.Origin)
+}
+
+// Validate checks the loaded CLI config: the base paths exist, the stacks 'included_paths' and 'excluded_paths' are valid
+// patterns matching the stack files, the name patterns only use the known '{token}' placeholders, and the settings don't conflict.
+// The paths are checked in the file system with the absolute paths. The issues are sorted by the config key
+func (c *Config) Validate(fss fs.FileSystem) []ValidationIssue {
+	v := &validator{config: c, fss: fss}
+
+	basePathExists := v.checkDir("base_path", c.BasePath)
+	if basePathExists {
+		v.checkDir("components.terraform.base_path", c.Components.Terraform.BasePath)
+		v.checkDir("components.helmfile.base_path", c.Components.Helmfile.BasePath)
+		v.checkDir("workflows.base_path", c.Workflows.BasePath)
+	}
+
+	stacksExist := basePathExists && v.checkDir("stacks.base_path", c.Stacks.BasePath)
+	v.checkStackPatterns(stacksExist)
+
+	v.checkNamePattern("stacks.name_pattern", c.Stacks.NamePattern)
+	v.checkNamePattern("components.helmfile.cluster_name_pattern", c.Components.Helmfile.ClusterNamePattern)
+	v.checkNamePattern("components.helmfile.helm_aws_profile_pattern", c.Components.Helmfile.HelmAwsProfilePattern)
+
+	v.checkConflicts()
+
+	sort.SliceStable(v.issues, func(i, j int) bool {
+		return v.issues[i].Key < v.issues[j].Key
+	})
+
+	return v.issues
+}
+
+type validator struct {
+	config *Config
+	fss    fs.FileSystem
+	issues []ValidationIssue
+}
+
+func (v *validator) add(severity string, key string, format string, args ...interface{}) {
+	origin, ok := v.config.Origins[key]
+	if !ok {
+		origin = OriginDefault
+	}
+	v.issues = append(v.issues, ValidationIssue{Severity: severity, Key: key, Message: fmt.Sprintf(format, args...), Origin: origin})
+}
+
+// path returns the absolute path of the base path, relative to 'base_path'
+func (v *validator) path(p string) string {
+	if filepath.IsAbs(p) {
+		return filepath.Clean(p)
+	}
+	return filepath.Join(v.config.BasePath, p)
+}
+
+// checkDir checks that the folder exists, and returns false if it doesn't
+func (v *validator) checkDir(key string, dir string) bool {
+	isDir, err := v.fss.IsDirectory(v.path(dir))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		v.add(SeverityError, key, "the folder '%s' does not exist", v.path(dir))
+	case err != nil:
+		v.add(SeverityError, key, "%v", err)
+	case !isDir:
+		v.add(SeverityError, key, "'%s' is not a folder", v.path(dir))
+	}
+	return err == nil && isDir
+}
+
+// checkStackPatterns checks that 'included_paths' and 'excluded_paths' are valid patterns, that each included pattern matches
+// at least one stack file, and that not all matched stack files are excluded
+func (v *validator) checkStackPatterns(stacksExist bool) {
+	var files []string
+	if stacksExist {
+		var err error
+		if files, err = v.listFiles(v.path(v.config.Stacks.BasePath), ""); err != nil {
+			v.add(SeverityError, "stacks.base_path", "%v", err)
+			stacksExist = false
+		}
+	}
+
+	matches := func(pattern string) []string {
+		var matched []string
+		for _, file := range files {
+			if ok, _ := doublestar.Match(pattern, file); ok {
+				matched = append(matched, file)
+			}
+		}
+		return matched
+	}
+
+	included := map[string]bool{}
+	for _, pattern := range v.config.Stacks.IncludedPaths {
+		if !doublestar.ValidatePattern(pattern) {
+			v.add(SeverityError, "stacks.included_paths", "invalid pattern '%s'", pattern)
+			continue
+		}
+		if !stacksExist {
+			continue
+		}
+		matched := matches(pattern)
+		if len(matched) == 0 {
+			v.add(SeverityError, "stacks.included_paths", "the pattern '%s' does not match any files in the stacks folder", pattern)
+		}
+		for _, file := range matched {
+			included[file] = true
+		}
+	}
+
+	excludedAll := len(included) > 0
+	for _, pattern := range v.config.Stacks.ExcludedPaths {
+		if !doublestar.ValidatePattern(pattern) {
+			v.add(SeverityError, "stacks.excluded_paths", "invalid pattern '%s'", pattern)
+			excludedAll = false
+			continue
+		}
+		if !stacksExist {
+			continue
+		}
+		if len(matches(pattern)) == 0 {
+			v.add(SeverityWarning, "stacks.excluded_paths", "the pattern '%s' does not match any files in the stacks folder", pattern)
+		}
+	}
+
+	if excludedAll {
+		for file := range included {
+			excluded := false
+			for _, pattern := range v.config.Stacks.ExcludedPaths {
+				if ok, _ := doublestar.Match(pattern, file); ok {
+					excluded = true
+					break
+				}
+			}
+			if !excluded {
+				excludedAll = false
+				break
+			}
+		}
+	}
+
+	if excludedAll {
+		v.add(SeverityError, "stacks.excluded_paths", "all stack files matched by 'included_paths' are excluded")
+	}
+}
+
+// listFiles returns the files in the folder and its sub-folders (slash-separated, relative to the folder)
+func (v *validator) listFiles(dir string, prefix string) ([]string, error) {
+	entries, err := v.fss.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		name := prefix + entry.Name()
+		if entry.IsDir() {
+			sub, err := v.listFiles(filepath.Join(dir, entry.Name()), name+"/")
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, sub...)
+			continue
+		}
+		files = append(files, name)
+	}
+
+	return files, nil
+}
+
+// checkNamePattern checks that the pattern only uses the known '{token}' placeholders
+func (v *validator) checkNamePattern(key string, pattern string) {
+	for _, match := range patternTokenRegexp.FindAllStringSubmatch(pattern, -1) {
+		known := false
+		for _, token := range patternTokens {
+			if match[1] == token {
+				known = true
+				break
+			}
+		}
+		if !known {
+			v.add(SeverityError, key, "unknown placeholder '%s' in the pattern '%s', the supported placeholders are '{%s}'",
+				match[0], pattern, strings.Join(patternTokens, "}', '{"))
+		}
+	}
+
+	if strings.Count(pattern, "{") != strings.Count(pattern, "}") {
+		v.add(SeverityError, key, "unbalanced braces in the pattern '%s'", pattern)
+	}
+}
+
+// checkConflicts checks the settings that conflict with each other
+func (v *validator) checkConflicts() {
+	folders := []struct {
+		key string
+		dir string
+	}{
+		{"components.terraform.base_path", v.config.Components.Terraform.BasePath},
+		{"components.helmfile.base_path", v.config.Components.Helmfile.BasePath},
+		{"stacks.base_path", v.config.Stacks.BasePath},
+		{"workflows.base_path", v.config.Workflows.BasePath},
+	}
+
+	for i, a := range folders {
+		for _, b := range folders[i+1:] {
+			if v.path(a.dir) == v.path(b.dir) {
+				v.add(SeverityError, b.key, "the folder '%s' is the same as '%s'", v.path(b.dir), a.key)
+			}
+		}
+	}
+
+	vendor := v.config.Vendor
+
+	if (vendor.Http.ClientCert == "") != (vendor.Http.ClientKey == "") {
+		v.add(SeverityError, "vendor.http.client_cert", "'vendor.http.client_cert' and 'vendor.http.client_key' must be set together")
+	}
+
+	if vendor.Policy.DenyHttp {
+		for _, scheme := range vendor.Policy.AllowedSchemes {
+			if strings.EqualFold(scheme, "http") {
+				v.add(SeverityError, "vendor.policy.allowed_schemes", "the scheme 'http' is allowed, but 'vendor.policy.deny_http' denies it")
+			}
+		}
+	}
+
+	if vendor.Policy.MaxArchiveSize < 0 {
+		v.add(SeverityError, "vendor.policy.max_archive_size", "must not be negative")
+	}
+	if vendor.Policy.MaxFileCount < 0 {
+		v.add(SeverityError, "vendor.policy.max_file_count", "must not be negative")
+	}
+	if vendor.Lock.Timeout < 0 {
+		v.add(SeverityError, "vendor.lock.timeout", "must not be negative")
+	}
+
+	if !vendor.SecretScan.Enabled && len(vendor.SecretScan.AllowedPaths) > 0 {
+		v.add(SeverityWarning, "vendor.secret_scan.allowed_paths", "has no effect, since 'vendor.secret_scan.enabled' is false")
+	}
+}
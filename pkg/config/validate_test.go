@@ -0,0 +1,87 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/home-sol/homectl/pkg/config"
+)
+
+func TestConfigValidate(t *testing.T) {
+	t.Setenv(config.ConfigFileEnvName, "")
+	t.Setenv(config.ProfileEnvName, "")
+
+	files := map[string]string{
+		"repo/components/terraform/vpc/main.tf": "",
+		"repo/components/helmfile/nginx/a.yaml": "",
+		"repo/stacks/orgs/home/prod.yaml":       "",
+		"repo/stacks/catalog/vpc.yaml":          "",
+		"repo/workflows/deploy.yaml":            "",
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		fss := newTestConfigFs(t, files)
+		require.NoError(t, fss.WriteFile("repo/homectl.yaml", []byte(`
+stacks:
+  included_paths:
+    - "orgs/**/*"
+  excluded_paths:
+    - "catalog/**/*"
+  name_pattern: "{tenant}-{environment}-{stage}"
+`), 0644))
+
+		cfg := loadTestConfig(t, fss, "/repo", nil)
+		assert.Empty(t, cfg.Validate(fss))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		fss := newTestConfigFs(t, files)
+		require.NoError(t, fss.WriteFile("repo/homectl.yaml", []byte(`
+components:
+  helmfile:
+    base_path: "components/terraform"
+    cluster_name_pattern: "{namespace}-{region}-eks"
+workflows:
+  base_path: "flows"
+stacks:
+  included_paths:
+    - "orgs/**/*"
+    - "sites/**/*"
+    - "[orgs"
+  excluded_paths:
+    - "orgs/**/*"
+    - "legacy/*"
+  name_pattern: "{tenant}-{stage"
+vendor:
+  http:
+    client_cert: "cert.pem"
+  policy:
+    deny_http: true
+    allowed_schemes: ["https", "http"]
+`), 0644))
+
+		cfg := loadTestConfig(t, fss, "/repo", nil)
+
+		var messages []string
+		for _, issue := range cfg.Validate(fss) {
+			assert.Equal(t, "file:/repo/homectl.yaml", issue.Origin, issue.Key)
+			messages = append(messages, issue.Severity+": "+issue.Key+": "+issue.Message)
+		}
+
+		assert.Equal(t, []string{
+			"error: components.helmfile.base_path: the folder '/repo/components/terraform' is the same as 'components.terraform.base_path'",
+			"error: components.helmfile.cluster_name_pattern: unknown placeholder '{region}' in the pattern '{namespace}-{region}-eks', " +
+				"the supported placeholders are '{namespace}', '{tenant}', '{environment}', '{stage}'",
+			"warning: stacks.excluded_paths: the pattern 'legacy/*' does not match any files in the stacks folder",
+			"error: stacks.excluded_paths: all stack files matched by 'included_paths' are excluded",
+			"error: stacks.included_paths: the pattern 'sites/**/*' does not match any files in the stacks folder",
+			"error: stacks.included_paths: invalid pattern '[orgs'",
+			"error: stacks.name_pattern: unbalanced braces in the pattern '{tenant}-{stage'",
+			"error: vendor.http.client_cert: 'vendor.http.client_cert' and 'vendor.http.client_key' must be set together",
+			"error: vendor.policy.allowed_schemes: the scheme 'http' is allowed, but 'vendor.policy.deny_http' denies it",
+			"error: workflows.base_path: the folder '/repo/flows' does not exist",
+		}, messages)
+	})
+}
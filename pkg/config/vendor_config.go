@@ -10,20 +10,14 @@ import (
 )
 
 // ReadComponentFile reads and processes `component.yaml` vendor config file
-func ReadComponentFile(fss *fs.FileSystem, component string, componentType string) (VendorComponentConfig, string, error) {
-	var componentBasePath string
+func (c *Config) ReadComponentFile(fss fs.FileSystem, component string, componentType string) (VendorComponentConfig, string, error) {
 	var componentConfig VendorComponentConfig
 
-	if componentType == "terraform" {
-		componentBasePath = Config.Components.Terraform.BasePath
-	} else if componentType == "helmfile" {
-		componentBasePath = Config.Components.Helmfile.BasePath
-	} else {
-		return componentConfig, "", fmt.Errorf("type '%s' is not supported. Valid types are 'terraform' and 'helmfile'", componentType)
+	componentPath, err := c.ComponentPath(component, componentType)
+	if err != nil {
+		return componentConfig, "", err
 	}
 
-	componentPath := path.Join(Config.BasePath, componentBasePath, component)
-
 	dirExists, err := fss.IsDirectory(componentPath)
 	if err != nil {
 		return componentConfig, "", err
@@ -55,3 +49,19 @@ func ReadComponentFile(fss *fs.FileSystem, component string, componentType strin
 
 	return componentConfig, componentPath, nil
 }
+
+// ComponentPath returns the path of the component folder for the component type ('terraform' or 'helmfile'),
+// relative to 'base_path
+func (c *Config) ComponentPath(component string, componentType string) (string, error) {
+	var componentBasePath string
+
+	if componentType == "terraform" {
+		componentBasePath = c.Components.Terraform.BasePath
+	} else if componentType == "helmfile" {
+		componentBasePath = c.Components.Helmfile.BasePath
+	} else {
+		return "", fmt.Errorf("type '%s' is not supported. Valid types are 'terraform' and 'helmfile'", componentType)
+	}
+
+	return path.Join(componentBasePath, component), nil
+}
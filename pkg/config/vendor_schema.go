@@ -1,11 +1,29 @@
 package config
 
 type VendorComponentSource struct {
-	Type          string   `yaml:"type" json:"type" mapstructure:"type"`
-	Uri           string   `yaml:"uri" json:"uri" mapstructure:"uri"`
-	Version       string   `yaml:"version" json:"version" mapstructure:"version"`
-	IncludedPaths []string `yaml:"included_paths" json:"included_paths" mapstructure:"included_paths"`
-	ExcludedPaths []string `yaml:"excluded_paths" json:"excluded_paths" mapstructure:"excluded_paths"`
+	Type            string                   `yaml:"type" json:"type" mapstructure:"type"`
+	Uri             string                   `yaml:"uri" json:"uri" mapstructure:"uri"`
+	Version         string                   `yaml:"version" json:"version" mapstructure:"version"`
+	Chart           string                   `yaml:"chart" json:"chart" mapstructure:"chart"`
+	IncludedPaths   []string                 `yaml:"included_paths" json:"included_paths" mapstructure:"included_paths"`
+	ExcludedPaths   []string                 `yaml:"excluded_paths" json:"excluded_paths" mapstructure:"excluded_paths"`
+	LocalizeModules bool                     `yaml:"localize_modules" json:"localize_modules" mapstructure:"localize_modules"`
+	Mappings        []VendorComponentMapping `yaml:"mappings" json:"mappings" mapstructure:"mappings"`
+	Rewrites        []VendorComponentRewrite `yaml:"rewrites" json:"rewrites" mapstructure:"rewrites"`
+	Symlinks        string                   `yaml:"symlinks" json:"symlinks" mapstructure:"symlinks"`
+	ExecutablePaths []string                 `yaml:"executable_paths" json:"executable_paths" mapstructure:"executable_paths"`
+}
+
+type VendorComponentMapping struct {
+	Source string `yaml:"source" json:"source" mapstructure:"source"`
+	Target string `yaml:"target" json:"target" mapstructure:"target"`
+}
+
+type VendorComponentRewrite struct {
+	Paths   []string `yaml:"paths" json:"paths" mapstructure:"paths"`
+	Regex   string   `yaml:"regex" json:"regex" mapstructure:"regex"`
+	Literal string   `yaml:"literal" json:"literal" mapstructure:"literal"`
+	Replace string   `yaml:"replace" json:"replace" mapstructure:"replace"`
 }
 
 type VendorComponentMixins struct {
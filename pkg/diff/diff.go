@@ -0,0 +1,153 @@
+package diff
+
+import (
+	"bytes"
+)
+
+// match is a pair of equal lines in two texts
+type match struct {
+	a int
+	b int
+}
+
+// SplitLines splits the content into lines. Each line keeps its line ending, so joining the lines gives the original content
+func SplitLines(content []byte) []string {
+	var lines []string
+
+	for len(content) > 0 {
+		i := bytes.IndexByte(content, '\n')
+		if i < 0 {
+			lines = append(lines, string(content))
+			break
+		}
+		lines = append(lines, string(content[:i+1]))
+		content = content[i+1:]
+	}
+
+	return lines
+}
+
+// IsBinary checks if the content looks like a binary file (contains a NUL byte in the first 8000 bytes, same as git)
+func IsBinary(content []byte) bool {
+	if len(content) > 8000 {
+		content = content[:8000]
+	}
+	return bytes.IndexByte(content, 0) >= 0
+}
+
+// matchLines returns the longest common subsequence of the lines as pairs of indexes, using the Myers diff algorithm
+// http://www.xmailserver.org/diff2.pdf
+func matchLines(a []string, b []string) []match {
+	// Common prefix and suffix are matched without running the algorithm
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var matches []match
+	for i := 0; i < prefix; i++ {
+		matches = append(matches, match{a: i, b: i})
+	}
+
+	for _, m := range myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]) {
+		matches = append(matches, match{a: m.a + prefix, b: m.b + prefix})
+	}
+
+	for i := suffix; i > 0; i-- {
+		matches = append(matches, match{a: len(a) - i, b: len(b) - i})
+	}
+
+	return matches
+}
+
+// maxEditDistance limits the number of the changed lines the Myers algorithm looks for. The trace it keeps for the backtracking
+// grows with the square of the changed lines, so the texts that differ more are reported as completely changed
+const maxEditDistance = 1000
+
+func myers(a []string, b []string) []match {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return nil
+	}
+
+	max := n + m
+	if max > maxEditDistance {
+		max = maxEditDistance
+	}
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	// trace[d] keeps only the diagonals -d-1..d+1 of 'v' the round 'd' starts with, the other ones aren't used by the backtracking
+	var trace [][]int
+
+	for d := 0; d <= max; d++ {
+		snapshot := make([]int, 2*d+3)
+		copy(snapshot, v[offset-d-1:offset+d+2])
+		trace = append(trace, snapshot)
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				return backtrack(trace, a, b, d)
+			}
+		}
+	}
+
+	return nil
+}
+
+// backtrack walks the trace of the Myers algorithm back from the end and collects the diagonal moves (matching lines)
+func backtrack(trace [][]int, a []string, b []string, d int) []match {
+	var matches []match
+
+	x, y := len(a), len(b)
+	for ; d >= 0; d-- {
+		// The diagonal 'k' is at 'k+d+1' in the trace of the round 'd'
+		v := trace[d]
+		offset := d + 1
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY && x > 0 && y > 0 {
+			x--
+			y--
+			matches = append(matches, match{a: x, b: y})
+		}
+
+		if d > 0 {
+			x, y = prevX, prevY
+		}
+	}
+
+	// Reverse into ascending order
+	for i, j := 0, len(matches)-1; i < j; i, j = i+1, j-1 {
+		matches[i], matches[j] = matches[j], matches[i]
+	}
+
+	return matches
+}
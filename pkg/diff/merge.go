@@ -0,0 +1,116 @@
+package diff
+
+import (
+	"strings"
+)
+
+const (
+	conflictMarkerLocal    = "<<<<<<<"
+	conflictMarkerSeparate = "======="
+	conflictMarkerUpstream = ">>>>>>>"
+)
+
+// Merge3 does a three-way merge of the 'local' and 'upstream' changes to the 'base' content, line by line (same as 'diff3 -m').
+// Changes made only on one side are applied. If both sides changed the same lines differently, the conflict is written
+// with the standard conflict markers labeled with 'localLabel' and 'upstreamLabel', and 'conflict' is 'true'
+func Merge3(base []byte, local []byte, upstream []byte, localLabel string, upstreamLabel string) (merged []byte, conflict bool) {
+	o := SplitLines(base)
+	a := SplitLines(local)
+	b := SplitLines(upstream)
+
+	// Maps from the base lines to the matching local and upstream lines
+	toLocal := matchIndex(matchLines(o, a), len(o))
+	toUpstream := matchIndex(matchLines(o, b), len(o))
+
+	var out strings.Builder
+	i, ia, ib := 0, 0, 0
+
+	for {
+		// Stable chunk: the base lines are unchanged on both sides
+		for i < len(o) && toLocal[i] == ia && toUpstream[i] == ib {
+			out.WriteString(o[i])
+			i, ia, ib = i+1, ia+1, ib+1
+		}
+
+		// Find the next base line that is unchanged on both sides
+		next := i
+		for next < len(o) && (toLocal[next] < 0 || toUpstream[next] < 0) {
+			next++
+		}
+
+		endA, endB := len(a), len(b)
+		if next < len(o) {
+			endA, endB = toLocal[next], toUpstream[next]
+		}
+
+		if i == next && ia == endA && ib == endB {
+			break
+		}
+
+		if mergeChunk(&out, o[i:next], a[ia:endA], b[ib:endB], localLabel, upstreamLabel) {
+			conflict = true
+		}
+
+		i, ia, ib = next, endA, endB
+	}
+
+	return []byte(out.String()), conflict
+}
+
+// mergeChunk writes the merged lines of an unstable chunk and returns 'true' if the chunk is a conflict
+func mergeChunk(out *strings.Builder, o []string, a []string, b []string, localLabel string, upstreamLabel string) bool {
+	switch {
+	case equalLines(a, o):
+		writeLines(out, b)
+	case equalLines(b, o) || equalLines(a, b):
+		writeLines(out, a)
+	default:
+		out.WriteString(conflictMarkerLocal + " " + localLabel + "\n")
+		writeConflictLines(out, a)
+		out.WriteString(conflictMarkerSeparate + "\n")
+		writeConflictLines(out, b)
+		out.WriteString(conflictMarkerUpstream + " " + upstreamLabel + "\n")
+		return true
+	}
+
+	return false
+}
+
+func matchIndex(matches []match, n int) []int {
+	index := make([]int, n)
+	for i := range index {
+		index[i] = -1
+	}
+	for _, m := range matches {
+		index[m.a] = m.b
+	}
+	return index
+}
+
+func equalLines(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func writeLines(out *strings.Builder, lines []string) {
+	for _, line := range lines {
+		out.WriteString(line)
+	}
+}
+
+// writeConflictLines writes the lines followed by a conflict marker, so the last line must end with a newline
+func writeConflictLines(out *strings.Builder, lines []string) {
+	for _, line := range lines {
+		out.WriteString(line)
+		if !strings.HasSuffix(line, "\n") {
+			out.WriteString("\n")
+		}
+	}
+}
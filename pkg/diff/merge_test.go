@@ -0,0 +1,74 @@
+package diff_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/home-sol/homectl/pkg/diff"
+)
+
+func TestMerge3(t *testing.T) {
+	base := "a\nb\nc\nd\ne\n"
+
+	tests := []struct {
+		name     string
+		local    string
+		upstream string
+		merged   string
+		conflict bool
+	}{
+		{
+			name:     "unchanged",
+			local:    base,
+			upstream: base,
+			merged:   base,
+		},
+		{
+			name:     "changed upstream",
+			local:    base,
+			upstream: "a\nB\nc\nd\ne\n",
+			merged:   "a\nB\nc\nd\ne\n",
+		},
+		{
+			name:     "changed locally",
+			local:    "a\nb\nc\nD\ne\n",
+			upstream: base,
+			merged:   "a\nb\nc\nD\ne\n",
+		},
+		{
+			name:     "changed different lines",
+			local:    "a\nb\nc\nD\ne\n",
+			upstream: "A\nb\nc\nd\ne\nf\n",
+			merged:   "A\nb\nc\nD\ne\nf\n",
+		},
+		{
+			name:     "same change on both sides",
+			local:    "a\nB\nc\nd\n",
+			upstream: "a\nB\nc\nd\n",
+			merged:   "a\nB\nc\nd\n",
+		},
+		{
+			name:     "conflict",
+			local:    "a\nb\nlocal\nd\ne\n",
+			upstream: "a\nb\nupstream\nd\ne\n",
+			merged:   "a\nb\n<<<<<<< local\nlocal\n=======\nupstream\n>>>>>>> upstream\nd\ne\n",
+			conflict: true,
+		},
+		{
+			name:     "conflict without newline at end of file",
+			local:    "a\nb\nc\nd\nlocal",
+			upstream: "a\nb\nc\nd\nupstream",
+			merged:   "a\nb\nc\nd\n<<<<<<< local\nlocal\n=======\nupstream\n>>>>>>> upstream\n",
+			conflict: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, conflict := diff.Merge3([]byte(base), []byte(tt.local), []byte(tt.upstream), "local", "upstream")
+			assert.Equal(t, tt.merged, string(merged))
+			assert.Equal(t, tt.conflict, conflict)
+		})
+	}
+}
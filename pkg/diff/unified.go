@@ -0,0 +1,144 @@
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// DevNull is the file name used in the diff header for a file that does not exist
+const DevNull = "/dev/null"
+
+type opKind byte
+
+const (
+	opEqual  opKind = ' '
+	opDelete opKind = '-'
+	opInsert opKind = '+'
+)
+
+// op is a line of the edit script with the positions in the 'from' and 'to' lines
+type op struct {
+	kind opKind
+	line string
+	a    int
+	b    int
+}
+
+// Unified returns the unified diff (same as 'diff -u') of the 'from' and 'to' content with 'context' lines around the changes.
+// Returns an empty string if the content is the same
+func Unified(fromName string, toName string, from []byte, to []byte, context int) string {
+	if bytes.Equal(from, to) {
+		return ""
+	}
+
+	if IsBinary(from) || IsBinary(to) {
+		return fmt.Sprintf("Binary files %s and %s differ\n", fromName, toName)
+	}
+
+	ops := editScript(SplitLines(from), SplitLines(to))
+
+	var out strings.Builder
+	out.WriteString("--- " + fromName + "\n")
+	out.WriteString("+++ " + toName + "\n")
+
+	for _, h := range hunks(ops, context) {
+		writeHunk(&out, ops[h[0]:h[1]])
+	}
+
+	return out.String()
+}
+
+// editScript converts the matching lines into the list of equal, deleted and inserted lines
+func editScript(a []string, b []string) []op {
+	var ops []op
+	i, j := 0, 0
+
+	for _, m := range append(matchLines(a, b), match{a: len(a), b: len(b)}) {
+		for ; i < m.a; i++ {
+			ops = append(ops, op{kind: opDelete, line: a[i], a: i, b: j})
+		}
+		for ; j < m.b; j++ {
+			ops = append(ops, op{kind: opInsert, line: b[j], a: i, b: j})
+		}
+		if m.a < len(a) {
+			ops = append(ops, op{kind: opEqual, line: a[i], a: i, b: j})
+			i, j = i+1, j+1
+		}
+	}
+
+	return ops
+}
+
+// hunks groups the changes into hunks, returning the ranges of the ops with the context lines.
+// The changes separated by not more than '2*context' equal lines are in the same hunk
+func hunks(ops []op, context int) [][2]int {
+	var result [][2]int
+
+	for i := 0; i < len(ops); i++ {
+		if ops[i].kind == opEqual {
+			continue
+		}
+
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+
+		// Extend the hunk to the last change that is close enough
+		end := i
+		for j := i + 1; j < len(ops) && j-end <= 2*context+1; j++ {
+			if ops[j].kind != opEqual {
+				end = j
+			}
+		}
+
+		i = end
+		end += context + 1
+		if end > len(ops) {
+			end = len(ops)
+		}
+
+		result = append(result, [2]int{start, end})
+	}
+
+	return result
+}
+
+func writeHunk(out *strings.Builder, ops []op) {
+	fromCount, toCount := 0, 0
+	for _, o := range ops {
+		if o.kind != opInsert {
+			fromCount++
+		}
+		if o.kind != opDelete {
+			toCount++
+		}
+	}
+
+	// Same as GNU diff, the empty range starts at the line before it
+	fromStart, toStart := ops[0].a, ops[0].b
+	if fromCount > 0 {
+		fromStart++
+	}
+	if toCount > 0 {
+		toStart++
+	}
+
+	out.WriteString(fmt.Sprintf("@@ -%s +%s @@\n", hunkRange(fromStart, fromCount), hunkRange(toStart, toCount)))
+
+	for _, o := range ops {
+		out.WriteByte(byte(o.kind))
+		out.WriteString(o.line)
+		if !strings.HasSuffix(o.line, "\n") {
+			out.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+func hunkRange(start int, count int) string {
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
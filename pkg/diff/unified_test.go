@@ -0,0 +1,82 @@
+package diff_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/home-sol/homectl/pkg/diff"
+)
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name string
+		from string
+		to   string
+		diff string
+	}{
+		{
+			name: "same content",
+			from: "a\nb\n",
+			to:   "a\nb\n",
+			diff: "",
+		},
+		{
+			name: "new file",
+			from: "",
+			to:   "a\nb\n",
+			diff: "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name: "changes in separate hunks",
+			from: "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			to:   "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n",
+			diff: "--- a\n+++ b\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n@@ -9,4 +9,3 @@\n 9\n 10\n 11\n-12\n",
+		},
+		{
+			name: "changes in the same hunk",
+			from: "1\n2\n3\n4\n5\n6\n7\n8\n",
+			to:   "1\ntwo\n3\n4\n5\n6\nseven\n8\n",
+			diff: "--- a\n+++ b\n@@ -1,8 +1,8 @@\n 1\n-2\n+two\n 3\n 4\n 5\n 6\n-7\n+seven\n 8\n",
+		},
+		{
+			name: "no newline at end of file",
+			from: "a\nb",
+			to:   "a\nc",
+			diff: "--- a\n+++ b\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file\n",
+		},
+		{
+			name: "binary",
+			from: "a\x00",
+			to:   "b\x00",
+			diff: "Binary files a and b differ\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.diff, diff.Unified("a", "b", []byte(tt.from), []byte(tt.to), 3))
+		})
+	}
+}
+
+func TestUnifiedLargeChanges(t *testing.T) {
+	var from, to strings.Builder
+	for i := 0; i < 1200; i++ {
+		if i == 600 {
+			from.WriteString("common\n")
+			to.WriteString("common\n")
+		}
+		fmt.Fprintf(&from, "a%d\n", i)
+		fmt.Fprintf(&to, "b%d\n", i)
+	}
+
+	// The texts that differ in too many lines are reported as completely changed, without the common lines in the middle
+	unified := diff.Unified("a", "b", []byte(from.String()), []byte(to.String()), 3)
+	assert.True(t, strings.HasPrefix(unified, "--- a\n+++ b\n@@ -1,1201 +1,1201 @@\n-a0\n"))
+	assert.NotContains(t, unified, " common\n")
+	assert.Contains(t, unified, "\n-common\n")
+	assert.Contains(t, unified, "\n+common\n")
+}
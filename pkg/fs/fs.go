@@ -1,52 +1,405 @@
 package fs
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
 )
 
-type FileSystem struct {
-	baseDir string
+// FileSystem is the file system with the paths relative to its base folder.
+// The write operations are containment-checked: absolute paths, paths escaping the base folder with '..',
+// and paths that resolve outside the base folder through symlinks are rejected
+type FileSystem interface {
+	// GetRelativePath returns the path of the file in the backend
+	GetRelativePath(relativePath string) string
+	IsDirectory(dir string) (bool, error)
+	// FileExists checks if a file exists and is not a directory
+	FileExists(filename string) bool
+	ReadFile(filename string) ([]byte, error)
+	// ReadDir returns the entries of the folder sorted by name
+	ReadDir(dir string) ([]os.FileInfo, error)
+	Stat(name string) (os.FileInfo, error)
+	// Lstat returns the info of the symlink itself (the same as 'Stat' if the backend does not support symlinks)
+	Lstat(name string) (os.FileInfo, error)
+	Readlink(name string) (string, error)
+	// Walk walks the folder like 'filepath.Walk' without following the symlinks.
+	// The paths passed to 'walkFn' are relative to the base folder
+	Walk(root string, walkFn filepath.WalkFunc) error
+
+	// SecurePath returns the path of the file in the backend. Absolute paths, paths escaping the base folder with '..',
+	// and paths that resolve outside the base folder through symlinks are rejected
+	SecurePath(name string) (string, error)
+	// Sub returns the file system for the folder inside the base folder
+	Sub(dir string) (FileSystem, error)
+	// TempDir creates a new temp folder in the backend and returns the file system for it.
+	// 'RemoveAll(".")' removes the temp folder
+	TempDir(pattern string) (FileSystem, error)
+
+	// MkdirAll creates the folder and its parents inside the base folder
+	MkdirAll(name string, perm os.FileMode) error
+	// WriteFile writes the file inside the base folder, creating the parent folders.
+	// An existing symlink with the same name is replaced and not written through
+	WriteFile(name string, data []byte, perm os.FileMode) error
+	// WriteFileFromReader writes the content from the reader into the file inside the base folder like 'WriteFile'
+	WriteFileFromReader(name string, r io.Reader, perm os.FileMode) error
+	// Symlink creates the symlink inside the base folder. The target must be relative and resolve inside the base folder.
+	// An existing file or symlink with the same name is replaced
+	Symlink(target string, name string) error
+	// Chmod changes the mode of the file inside the base folder
+	Chmod(name string, mode os.FileMode) error
+	// Rename moves the file or the folder inside the base folder, creating the parent folders of the new path
+	Rename(oldname string, newname string) error
+	// Remove removes the file or the empty folder inside the base folder. A symlink is removed itself, not the file it points to
+	Remove(name string) error
+	// RemoveAll removes the file or the folder with its content inside the base folder.
+	// A symlink is removed itself, not the file it points to
+	RemoveAll(name string) error
+
+	// TryLock takes the shared or exclusive advisory lock on the file inside the base folder without waiting,
+	// creating the file. 'ErrLocked' is returned if the lock is held by another holder
+	TryLock(name string, exclusive bool) (Lock, error)
 }
 
-func Cwd() (*FileSystem, error) {
+// ErrOutsideBaseDir is returned by the write APIs for the paths that resolve outside of the base folder
+var ErrOutsideBaseDir = errors.New("path is outside of the base folder")
+
+func outsideError(name string, format string, args ...interface{}) error {
+	return fmt.Errorf("'%s' %s: %w", name, fmt.Sprintf(format, args...), ErrOutsideBaseDir)
+}
+
+// Cwd returns the OS file system with the current folder as the base folder
+func Cwd() (FileSystem, error) {
 	cwd, err := os.Getwd()
 	if err != nil {
 		return nil, err
 	}
-	return &FileSystem{baseDir: cwd}, nil
+	return FromDir(cwd)
 }
 
-func FromDir(dir string) (*FileSystem, error) {
-	return &FileSystem{baseDir: dir}, nil
+// FromDir returns the OS file system with the base folder 'dir'
+func FromDir(dir string) (FileSystem, error) {
+	return FromAfero(afero.NewOsFs(), dir), nil
 }
 
-func (fs *FileSystem) GetRelativePath(relativePath string) string {
+// NewMemFileSystem returns the empty in-memory file system with '/' as the base folder
+func NewMemFileSystem() FileSystem {
+	return FromAfero(afero.NewMemMapFs(), "/")
+}
+
+// FromAfero returns the file system using the afero backend with the base folder 'dir'
+func FromAfero(afs afero.Fs, dir string) FileSystem {
+	return &aferoFileSystem{afs: afs, baseDir: dir}
+}
+
+type aferoFileSystem struct {
+	afs     afero.Fs
+	baseDir string
+}
+
+func (fs *aferoFileSystem) GetRelativePath(relativePath string) string {
 	return path.Join(fs.baseDir, relativePath)
 }
 
-func (fs *FileSystem) IsDirectory(dir string) (bool, error) {
-	fileInfo, err := os.Stat(fs.GetRelativePath(dir))
+func (fs *aferoFileSystem) IsDirectory(dir string) (bool, error) {
+	fileInfo, err := fs.afs.Stat(fs.GetRelativePath(dir))
 	if err != nil {
 		return false, err
 	}
 	return fileInfo.IsDir(), err
 }
 
-// FileExists checks if a file exists and is not a directory
-func (fs *FileSystem) FileExists(filename string) bool {
-	fileInfo, err := os.Stat(fs.GetRelativePath(filename))
-	if os.IsNotExist(err) {
+func (fs *aferoFileSystem) FileExists(filename string) bool {
+	fileInfo, err := fs.afs.Stat(fs.GetRelativePath(filename))
+	if err != nil {
 		return false
 	}
 	return !fileInfo.IsDir()
 }
 
-func (fs *FileSystem) ReadFile(filename string) ([]byte, error) {
-	return ioutil.ReadFile(fs.GetRelativePath(filename))
+func (fs *aferoFileSystem) ReadFile(filename string) ([]byte, error) {
+	return afero.ReadFile(fs.afs, fs.GetRelativePath(filename))
+}
+
+func (fs *aferoFileSystem) ReadDir(dir string) ([]os.FileInfo, error) {
+	return afero.ReadDir(fs.afs, fs.GetRelativePath(dir))
+}
+
+func (fs *aferoFileSystem) Stat(name string) (os.FileInfo, error) {
+	return fs.afs.Stat(fs.GetRelativePath(name))
+}
+
+func (fs *aferoFileSystem) Lstat(name string) (os.FileInfo, error) {
+	return fs.lstat(fs.GetRelativePath(name))
+}
+
+func (fs *aferoFileSystem) lstat(p string) (os.FileInfo, error) {
+	if lstater, ok := fs.afs.(afero.Lstater); ok {
+		info, _, err := lstater.LstatIfPossible(p)
+		return info, err
+	}
+	return fs.afs.Stat(p)
+}
+
+func (fs *aferoFileSystem) Readlink(name string) (string, error) {
+	p := fs.GetRelativePath(name)
+	if reader, ok := fs.afs.(afero.LinkReader); ok {
+		return reader.ReadlinkIfPossible(p)
+	}
+	return "", &os.PathError{Op: "readlink", Path: p, Err: afero.ErrNoReadlink}
+}
+
+func (fs *aferoFileSystem) Walk(root string, walkFn filepath.WalkFunc) error {
+	base := filepath.Clean(filepath.FromSlash(fs.GetRelativePath(".")))
+
+	return afero.Walk(fs.afs, fs.GetRelativePath(root), func(p string, info os.FileInfo, err error) error {
+		rel, relErr := filepath.Rel(base, p)
+		if relErr != nil {
+			return relErr
+		}
+		return walkFn(rel, info, err)
+	})
+}
+
+// evalSymlinks resolves the symlinks in the path. Only the OS backend supports symlinks
+func (fs *aferoFileSystem) evalSymlinks(p string) (string, error) {
+	if _, ok := fs.afs.(*afero.OsFs); ok {
+		return filepath.EvalSymlinks(p)
+	}
+	return p, nil
 }
 
-func (fs *FileSystem) Remove(name string) error {
-	return os.Remove(fs.GetRelativePath(name))
+func (fs *aferoFileSystem) abs(p string) (string, error) {
+	if _, ok := fs.afs.(*afero.OsFs); ok {
+		return filepath.Abs(p)
+	}
+	return filepath.Join(string(filepath.Separator), p), nil
+}
+
+func (fs *aferoFileSystem) Sub(dir string) (FileSystem, error) {
+	p, err := fs.SecurePath(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &aferoFileSystem{afs: fs.afs, baseDir: p}, nil
+}
+
+func (fs *aferoFileSystem) TempDir(pattern string) (FileSystem, error) {
+	if _, ok := fs.afs.(*afero.OsFs); ok {
+		dir, err := ioutil.TempDir("", pattern)
+		if err != nil {
+			return nil, err
+		}
+		return &aferoFileSystem{afs: fs.afs, baseDir: dir}, nil
+	}
+
+	if err := fs.afs.MkdirAll(os.TempDir(), 0755); err != nil {
+		return nil, err
+	}
+
+	dir, err := afero.TempDir(fs.afs, "", pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &aferoFileSystem{afs: fs.afs, baseDir: dir}, nil
+}
+
+func (fs *aferoFileSystem) SecurePath(name string) (string, error) {
+	return fs.securePath(name, true)
+}
+
+// securePath checks the path like 'SecurePath'. If 'followLeaf' is false, the last element of the path is not resolved
+// if it's a symlink (the symlink itself is removed or replaced)
+func (fs *aferoFileSystem) securePath(name string, followLeaf bool) (string, error) {
+	if filepath.IsAbs(name) || path.IsAbs(filepath.ToSlash(name)) {
+		return "", outsideError(name, "is an absolute path")
+	}
+
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", outsideError(name, "escapes the base folder with '..'")
+	}
+
+	base, err := fs.abs(fs.baseDir)
+	if err != nil {
+		return "", err
+	}
+
+	realBase, err := fs.evalSymlinks(base)
+	if errors.Is(err, os.ErrNotExist) {
+		realBase = base
+	} else if err != nil {
+		return "", err
+	}
+
+	if clean == "." {
+		return base, nil
+	}
+
+	// Each existing element of the path that is a symlink must resolve inside the base folder.
+	// The elements after the first missing one are created inside the checked folder
+	elements := strings.Split(clean, string(filepath.Separator))
+	current := base
+
+	for i, element := range elements {
+		current = filepath.Join(current, element)
+
+		if i == len(elements)-1 && !followLeaf {
+			break
+		}
+
+		info, err := fs.lstat(current)
+		if errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if info.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+
+		resolved, err := fs.evalSymlinks(current)
+		if err != nil {
+			return "", fmt.Errorf("error resolving the symlink '%s' in '%s': %w", filepath.Join(elements[:i+1]...), name, err)
+		}
+
+		rel, err := filepath.Rel(realBase, resolved)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(rel) {
+			return "", outsideError(name, "resolves outside the base folder through the symlink '%s'", filepath.Join(elements[:i+1]...))
+		}
+	}
+
+	return filepath.Join(base, clean), nil
+}
+
+func (fs *aferoFileSystem) MkdirAll(name string, perm os.FileMode) error {
+	p, err := fs.SecurePath(name)
+	if err != nil {
+		return err
+	}
+	return fs.afs.MkdirAll(p, perm)
+}
+
+func (fs *aferoFileSystem) WriteFile(name string, data []byte, perm os.FileMode) error {
+	return fs.WriteFileFromReader(name, bytes.NewReader(data), perm)
+}
+
+func (fs *aferoFileSystem) WriteFileFromReader(name string, r io.Reader, perm os.FileMode) error {
+	p, err := fs.prepareWrite(name)
+	if err != nil {
+		return err
+	}
+
+	f, err := fs.afs.OpenFile(p, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(f, r); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	if err = f.Close(); err != nil {
+		return err
+	}
+
+	// The mode of the existing file is not changed by 'OpenFile'
+	return fs.afs.Chmod(p, perm)
+}
+
+func (fs *aferoFileSystem) Symlink(target string, name string) error {
+	p, err := fs.prepareWrite(name)
+	if err != nil {
+		return err
+	}
+
+	if filepath.IsAbs(target) || path.IsAbs(filepath.ToSlash(target)) {
+		return outsideError(name, "is a symlink to the absolute path '%s'", target)
+	}
+
+	// The target is checked relative to the folder of the symlink
+	if _, err = fs.SecurePath(filepath.Join(filepath.Dir(filepath.Clean(filepath.FromSlash(name))), filepath.FromSlash(target))); err != nil {
+		return outsideError(name, "is a symlink to '%s'", target)
+	}
+
+	linker, ok := fs.afs.(afero.Linker)
+	if !ok {
+		return &os.LinkError{Op: "symlink", Old: target, New: p, Err: afero.ErrNoSymlink}
+	}
+
+	if info, err := fs.lstat(p); err == nil && !info.IsDir() {
+		if err = fs.afs.Remove(p); err != nil {
+			return err
+		}
+	}
+
+	return linker.SymlinkIfPossible(target, p)
+}
+
+// prepareWrite checks the path, creates the parent folders, and removes the existing symlink
+func (fs *aferoFileSystem) prepareWrite(name string) (string, error) {
+	p, err := fs.securePath(name, false)
+	if err != nil {
+		return "", err
+	}
+
+	if err = fs.MkdirAll(filepath.Dir(filepath.Clean(filepath.FromSlash(name))), 0755); err != nil {
+		return "", err
+	}
+
+	if info, err := fs.lstat(p); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		if err = fs.afs.Remove(p); err != nil {
+			return "", err
+		}
+	}
+
+	return p, nil
+}
+
+func (fs *aferoFileSystem) Chmod(name string, mode os.FileMode) error {
+	p, err := fs.SecurePath(name)
+	if err != nil {
+		return err
+	}
+	return fs.afs.Chmod(p, mode)
+}
+
+func (fs *aferoFileSystem) Rename(oldname string, newname string) error {
+	oldPath, err := fs.securePath(oldname, false)
+	if err != nil {
+		return err
+	}
+
+	newPath, err := fs.prepareWrite(newname)
+	if err != nil {
+		return err
+	}
+
+	return fs.afs.Rename(oldPath, newPath)
+}
+
+func (fs *aferoFileSystem) Remove(name string) error {
+	p, err := fs.securePath(name, false)
+	if err != nil {
+		return err
+	}
+	return fs.afs.Remove(p)
+}
+
+func (fs *aferoFileSystem) RemoveAll(name string) error {
+	p, err := fs.securePath(name, false)
+	if err != nil {
+		return err
+	}
+	return fs.afs.RemoveAll(p)
 }
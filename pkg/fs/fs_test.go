@@ -0,0 +1,201 @@
+package fs_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/home-sol/homectl/pkg/fs"
+)
+
+// newHostileFileSystem returns the file system with symlinks pointing outside of its base folder, and the outside folder
+func newHostileFileSystem(t *testing.T) (fs.FileSystem, string, string) {
+	base := t.TempDir()
+	outside := t.TempDir()
+
+	require.NoError(t, os.MkdirAll(filepath.Join(base, "sub"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(outside, "target.txt"), []byte("outside\n"), 0644))
+
+	// 'escape' points to the outside folder, 'leaf.txt' to a file in it, and 'inside' to a folder in the base folder
+	require.NoError(t, os.Symlink(outside, filepath.Join(base, "escape")))
+	require.NoError(t, os.Symlink(filepath.Join(outside, "target.txt"), filepath.Join(base, "leaf.txt")))
+	require.NoError(t, os.Symlink("sub", filepath.Join(base, "inside")))
+	require.NoError(t, os.Symlink("../escape", filepath.Join(base, "sub", "chain")))
+
+	fss, err := fs.FromDir(base)
+	require.NoError(t, err)
+
+	return fss, base, outside
+}
+
+func TestSecurePath(t *testing.T) {
+	fss, base, _ := newHostileFileSystem(t)
+
+	for _, name := range []string{"a.txt", "a/b/c.txt", "./a/../b.txt", "inside/a.txt", "sub", "."} {
+		p, err := fss.SecurePath(name)
+		if assert.NoError(t, err, name) {
+			assert.Equal(t, filepath.Join(base, filepath.Clean(name)), p, name)
+		}
+	}
+
+	for _, name := range []string{
+		"/etc/passwd",
+		"..",
+		"../a.txt",
+		"a/../../a.txt",
+		"escape",
+		"escape/a.txt",
+		"leaf.txt",
+		"sub/chain/a.txt",
+		"inside/chain/a.txt",
+	} {
+		_, err := fss.SecurePath(name)
+		assert.ErrorIs(t, err, fs.ErrOutsideBaseDir, name)
+	}
+}
+
+func TestFileSystemWrite(t *testing.T) {
+	fss, base, outside := newHostileFileSystem(t)
+
+	require.NoError(t, fss.WriteFile("a/b/c.txt", []byte("c\n"), 0600))
+	info, err := os.Stat(filepath.Join(base, "a", "b", "c.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// The mode of the existing file is changed
+	require.NoError(t, fss.WriteFile("a/b/c.txt", []byte("c\n"), 0644))
+	info, err = os.Stat(filepath.Join(base, "a", "b", "c.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+
+	for _, name := range []string{"/tmp/a.txt", "../a.txt", "escape/a.txt", "sub/chain/a.txt"} {
+		assert.ErrorIs(t, fss.WriteFile(name, []byte("a\n"), 0644), fs.ErrOutsideBaseDir, name)
+		assert.ErrorIs(t, fss.MkdirAll(name, 0755), fs.ErrOutsideBaseDir, name)
+	}
+	assert.NoFileExists(t, filepath.Join(outside, "a.txt"))
+
+	// The symlink is replaced, the file it points to is not written
+	require.NoError(t, fss.WriteFile("leaf.txt", []byte("leaf\n"), 0644))
+	content, err := ioutil.ReadFile(filepath.Join(outside, "target.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "outside\n", string(content))
+	info, err = os.Lstat(filepath.Join(base, "leaf.txt"))
+	require.NoError(t, err)
+	assert.True(t, info.Mode().IsRegular())
+
+	// Chmod follows the symlinks, so it's checked like the writes
+	assert.ErrorIs(t, fss.Chmod("escape", 0777), fs.ErrOutsideBaseDir)
+}
+
+func TestFileSystemSymlink(t *testing.T) {
+	fss, base, outside := newHostileFileSystem(t)
+
+	require.NoError(t, fss.Symlink("../sub", "a/link"))
+	target, err := os.Readlink(filepath.Join(base, "a", "link"))
+	require.NoError(t, err)
+	assert.Equal(t, "../sub", target)
+
+	// The existing file is replaced
+	require.NoError(t, fss.WriteFile("file.txt", []byte("file\n"), 0644))
+	require.NoError(t, fss.Symlink("sub", "file.txt"))
+	info, err := os.Lstat(filepath.Join(base, "file.txt"))
+	require.NoError(t, err)
+	assert.NotZero(t, info.Mode()&os.ModeSymlink)
+
+	for target, name := range map[string]string{
+		outside:          "abs",
+		"../../a.txt":    "a/rel",
+		"escape/a.txt":   "through",
+		"../escape":      "sub/escape",
+		"chain/a.txt":    "sub/chained",
+		"inside/../a.md": "escape/link",
+	} {
+		assert.ErrorIs(t, fss.Symlink(target, name), fs.ErrOutsideBaseDir, name)
+	}
+}
+
+func TestFileSystemRemove(t *testing.T) {
+	fss, base, outside := newHostileFileSystem(t)
+
+	// The symlinks are removed, not the files and folders they point to
+	require.NoError(t, fss.Remove("leaf.txt"))
+	require.NoError(t, fss.RemoveAll("escape"))
+	assert.FileExists(t, filepath.Join(outside, "target.txt"))
+	assert.NoFileExists(t, filepath.Join(base, "leaf.txt"))
+
+	fss, _, outside = newHostileFileSystem(t)
+
+	assert.ErrorIs(t, fss.Remove("escape/target.txt"), fs.ErrOutsideBaseDir)
+	assert.ErrorIs(t, fss.RemoveAll("sub/chain/target.txt"), fs.ErrOutsideBaseDir)
+	assert.ErrorIs(t, fss.RemoveAll(".."), fs.ErrOutsideBaseDir)
+	assert.FileExists(t, filepath.Join(outside, "target.txt"))
+
+	_, err := fss.Sub("escape")
+	assert.ErrorIs(t, err, fs.ErrOutsideBaseDir)
+
+	sub, err := fss.Sub("sub")
+	require.NoError(t, err)
+	assert.ErrorIs(t, sub.WriteFile("../a.txt", []byte("a\n"), 0644), fs.ErrOutsideBaseDir)
+}
+
+func TestMemFileSystem(t *testing.T) {
+	fss := fs.NewMemFileSystem()
+
+	require.NoError(t, fss.WriteFile("a/b/c.txt", []byte("c\n"), 0644))
+	require.NoError(t, fss.WriteFile("a/d.txt", []byte("d\n"), 0644))
+
+	content, err := fss.ReadFile("a/b/c.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "c\n", string(content))
+	assert.True(t, fss.FileExists("a/d.txt"))
+
+	var files []string
+	require.NoError(t, fss.Walk("a", func(name string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			files = append(files, filepath.ToSlash(name))
+		}
+		return err
+	}))
+	assert.Equal(t, []string{"a/b/c.txt", "a/d.txt"}, files)
+
+	require.NoError(t, fss.Rename("a/d.txt", "e.txt"))
+	assert.False(t, fss.FileExists("a/d.txt"))
+	assert.True(t, fss.FileExists("e.txt"))
+
+	sub, err := fss.Sub("a")
+	require.NoError(t, err)
+	assert.True(t, sub.FileExists("b/c.txt"))
+	require.NoError(t, sub.WriteFile("f.txt", []byte("f\n"), 0644))
+	assert.True(t, fss.FileExists("a/f.txt"))
+
+	_, err = sub.SecurePath("../e.txt")
+	assert.ErrorIs(t, err, fs.ErrOutsideBaseDir)
+
+	// The in-memory backend doesn't support symlinks
+	assert.Error(t, fss.Symlink("e.txt", "link.txt"))
+
+	require.NoError(t, fss.RemoveAll("a"))
+	assert.False(t, fss.FileExists("a/b/c.txt"))
+}
+
+func TestFileSystemTempDir(t *testing.T) {
+	for name, fss := range map[string]fs.FileSystem{
+		"os":  func() fs.FileSystem { fss, _ := fs.FromDir(t.TempDir()); return fss }(),
+		"mem": fs.NewMemFileSystem(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			temp, err := fss.TempDir("homectl-test-")
+			require.NoError(t, err)
+
+			require.NoError(t, temp.WriteFile("a.txt", []byte("a\n"), 0644))
+			assert.True(t, temp.FileExists("a.txt"))
+
+			require.NoError(t, temp.RemoveAll("."))
+			assert.False(t, temp.FileExists("a.txt"))
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package fs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// ErrLocked is returned by 'TryLock' if the lock is held by another holder
+var ErrLocked = errors.New("the lock is held by another process")
+
+// Lock is the advisory lock taken by 'TryLock'
+type Lock interface {
+	// Unlock releases the lock. The lock file is kept, since removing it would race with the processes waiting for it
+	Unlock() error
+}
+
+// LockHolder is the process holding the lock, recorded in the lock file
+type LockHolder struct {
+	Pid   int       `json:"pid"`
+	Host  string    `json:"host"`
+	Since time.Time `json:"since"`
+}
+
+func currentLockHolder() LockHolder {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return LockHolder{Pid: os.Getpid(), Host: host, Since: time.Now().UTC().Truncate(time.Second)}
+}
+
+func lockedError(name string, holder *LockHolder) error {
+	if holder == nil || holder.Pid == 0 {
+		return fmt.Errorf("'%s': %w", name, ErrLocked)
+	}
+	return fmt.Errorf("'%s' is locked by the process %d on the host '%s' since %s: %w",
+		name, holder.Pid, holder.Host, holder.Since.Format(time.RFC3339), ErrLocked)
+}
+
+func (fs *aferoFileSystem) TryLock(name string, exclusive bool) (Lock, error) {
+	p, err := fs.prepareWrite(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// The OS backend uses the OS file locks, so the lock is visible to the other processes
+	if _, ok := fs.afs.(*afero.OsFs); ok {
+		return tryLockFile(name, p, exclusive)
+	}
+
+	return memLocks.tryLock(fs.afs, name, p, exclusive)
+}
+
+// osLock is the lock on the OS file ('flock' on Unix and 'LockFileEx' on Windows)
+type osLock struct {
+	file *os.File
+}
+
+func tryLockFile(name string, p string, exclusive bool) (Lock, error) {
+	file, err := os.OpenFile(p, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	locked, err := lockFile(file, exclusive)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	if !locked {
+		var holder *LockHolder
+		if content, err := ioutil.ReadAll(file); err == nil {
+			holder = &LockHolder{}
+			if json.Unmarshal(content, holder) != nil {
+				holder = nil
+			}
+		}
+		_ = file.Close()
+		return nil, lockedError(name, holder)
+	}
+
+	// With the shared locks, the file records the last holder
+	content, err := json.Marshal(currentLockHolder())
+	if err == nil {
+		if err = file.Truncate(0); err == nil {
+			_, err = file.WriteAt(content, 0)
+		}
+	}
+	if err != nil {
+		_ = unlockFile(file)
+		_ = file.Close()
+		return nil, err
+	}
+
+	return &osLock{file: file}, nil
+}
+
+func (l *osLock) Unlock() error {
+	if err := unlockFile(l.file); err != nil {
+		_ = l.file.Close()
+		return err
+	}
+	return l.file.Close()
+}
+
+// memLockTable holds the locks of the in-memory backends, which are only visible in the current process
+type memLockTable struct {
+	mu    sync.Mutex
+	locks map[afero.Fs]map[string]*memLockState
+}
+
+type memLockState struct {
+	shared    int
+	exclusive bool
+	holder    LockHolder
+}
+
+var memLocks = &memLockTable{locks: map[afero.Fs]map[string]*memLockState{}}
+
+func (t *memLockTable) tryLock(afs afero.Fs, name string, p string, exclusive bool) (Lock, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.locks[afs] == nil {
+		t.locks[afs] = map[string]*memLockState{}
+	}
+
+	state := t.locks[afs][p]
+	if state == nil {
+		state = &memLockState{}
+		t.locks[afs][p] = state
+	}
+
+	if state.exclusive || (exclusive && state.shared > 0) {
+		holder := state.holder
+		return nil, lockedError(name, &holder)
+	}
+
+	if exclusive {
+		state.exclusive = true
+	} else {
+		state.shared++
+	}
+	state.holder = currentLockHolder()
+
+	return &memLock{table: t, state: state, exclusive: exclusive}, nil
+}
+
+type memLock struct {
+	table     *memLockTable
+	state     *memLockState
+	exclusive bool
+	once      sync.Once
+}
+
+func (l *memLock) Unlock() error {
+	l.once.Do(func() {
+		l.table.mu.Lock()
+		defer l.table.mu.Unlock()
+
+		if l.exclusive {
+			l.state.exclusive = false
+		} else {
+			l.state.shared--
+		}
+	})
+	return nil
+}
@@ -0,0 +1,67 @@
+package fs_test
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/home-sol/homectl/pkg/fs"
+)
+
+func TestFileSystemTryLock(t *testing.T) {
+	for name, fss := range map[string]fs.FileSystem{
+		"os":  func() fs.FileSystem { fss, _ := fs.FromDir(t.TempDir()); return fss }(),
+		"mem": fs.NewMemFileSystem(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			exclusive, err := fss.TryLock("locks/a.lock", true)
+			require.NoError(t, err)
+
+			// The error names the process and the host holding the lock
+			host, err := os.Hostname()
+			require.NoError(t, err)
+
+			_, err = fss.TryLock("locks/a.lock", true)
+			assert.ErrorIs(t, err, fs.ErrLocked)
+			assert.Contains(t, err.Error(), fmt.Sprintf("locked by the process %d on the host '%s'", os.Getpid(), host))
+
+			_, err = fss.TryLock("locks/a.lock", false)
+			assert.ErrorIs(t, err, fs.ErrLocked)
+
+			// The other lock files are not affected
+			other, err := fss.TryLock("locks/b.lock", true)
+			require.NoError(t, err)
+			require.NoError(t, other.Unlock())
+
+			require.NoError(t, exclusive.Unlock())
+
+			// The shared locks are only exclusive with the exclusive lock
+			shared1, err := fss.TryLock("locks/a.lock", false)
+			require.NoError(t, err)
+			shared2, err := fss.TryLock("locks/a.lock", false)
+			require.NoError(t, err)
+
+			_, err = fss.TryLock("locks/a.lock", true)
+			assert.ErrorIs(t, err, fs.ErrLocked)
+
+			require.NoError(t, shared1.Unlock())
+			require.NoError(t, shared2.Unlock())
+
+			exclusive, err = fss.TryLock("locks/a.lock", true)
+			require.NoError(t, err)
+			require.NoError(t, exclusive.Unlock())
+		})
+	}
+}
+
+func TestFileSystemTryLockOutside(t *testing.T) {
+	fss, _, _ := newHostileFileSystem(t)
+
+	for _, name := range []string{"../a.lock", "escape/a.lock"} {
+		_, err := fss.TryLock(name, true)
+		assert.ErrorIs(t, err, fs.ErrOutsideBaseDir, name)
+	}
+}
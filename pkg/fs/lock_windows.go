@@ -0,0 +1,41 @@
+//go:build windows
+// +build windows
+
+package fs
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockRange is the locked byte range of the lock file, beyond its content, so the holder recorded in the file can be read
+func lockRange() *windows.Overlapped {
+	return &windows.Overlapped{Offset: 0xFFFFFFFF, OffsetHigh: 0x7FFFFFFF}
+}
+
+// lockFile takes the 'LockFileEx' lock on the file without waiting, and returns false if the lock is held by another process
+func lockFile(file *os.File, exclusive bool) (bool, error) {
+	flags := uint32(windows.LOCKFILE_FAIL_IMMEDIATELY)
+	if exclusive {
+		flags |= windows.LOCKFILE_EXCLUSIVE_LOCK
+	}
+
+	err := windows.LockFileEx(windows.Handle(file.Fd()), flags, 0, 1, 0, lockRange())
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, windows.ERROR_LOCK_VIOLATION):
+		return false, nil
+	default:
+		return false, &os.PathError{Op: "LockFileEx", Path: file.Name(), Err: err}
+	}
+}
+
+func unlockFile(file *os.File) error {
+	if err := windows.UnlockFileEx(windows.Handle(file.Fd()), 0, 1, 0, lockRange()); err != nil {
+		return &os.PathError{Op: "UnlockFileEx", Path: file.Name(), Err: err}
+	}
+	return nil
+}
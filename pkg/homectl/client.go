@@ -0,0 +1,269 @@
+// Package homectl is the Go API of homectl, used to embed homectl into other tools.
+// The 'homectl' CLI commands are thin wrappers over the 'Client'
+package homectl
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+
+	"github.com/home-sol/homectl/pkg/config"
+	"github.com/home-sol/homectl/pkg/fs"
+	"github.com/home-sol/homectl/pkg/scaffold"
+	"github.com/home-sol/homectl/pkg/vender"
+)
+
+// Client runs the homectl operations with the CLI config loaded for the project folder.
+// The clients don't share any state, so several clients with different configs can be used at the same time
+type Client struct {
+	ctx context.Context
+	dir string
+	// The file system with the absolute paths, or nil to use the OS file system
+	fs     fs.FileSystem
+	flags  *pflag.FlagSet
+	logger *zap.SugaredLogger
+	config *config.Config
+	// Don't load the CLI config, see 'WithoutConfig'
+	withoutConfig bool
+}
+
+// Option configures the client
+type Option func(*Client)
+
+// WithDir sets the folder in which (or in whose parents) the project config 'homectl.yaml' is found.
+// By default, the current folder is used
+func WithDir(dir string) Option {
+	return func(c *Client) {
+		c.dir = dir
+	}
+}
+
+// WithFileSystem sets the file system with '/' as the base folder (e.g. 'fs.NewMemFileSystem()'), in which the config files,
+// the project folder and 'base_path' are the absolute paths. By default, the OS file system is used
+func WithFileSystem(fss fs.FileSystem) Option {
+	return func(c *Client) {
+		c.fs = fss
+	}
+}
+
+// WithLogger sets the logger. By default, nothing is logged
+func WithLogger(l *zap.SugaredLogger) Option {
+	return func(c *Client) {
+		c.logger = l
+	}
+}
+
+// WithContext sets the context cancelling the downloads. By default, 'context.Background()' is used
+func WithContext(ctx context.Context) Option {
+	return func(c *Client) {
+		c.ctx = ctx
+	}
+}
+
+// WithFlags sets the command-line flags (see 'config.AddFlags') overriding the config files and the ENV vars
+func WithFlags(flags *pflag.FlagSet) Option {
+	return func(c *Client) {
+		c.flags = flags
+	}
+}
+
+// WithoutConfig skips loading the CLI config, so the client works without a valid 'homectl.yaml'.
+// Only 'Init' can be used with such a client
+func WithoutConfig() Option {
+	return func(c *Client) {
+		c.withoutConfig = true
+	}
+}
+
+// New returns the client with the CLI config loaded for the project folder
+func New(options ...Option) (*Client, error) {
+	c := &Client{
+		ctx:    context.Background(),
+		logger: zap.NewNop().Sugar(),
+	}
+
+	for _, option := range options {
+		option(c)
+	}
+
+	if c.dir == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		c.dir = wd
+	}
+
+	if c.withoutConfig {
+		return c, nil
+	}
+
+	configFs, err := c.rootFs()
+	if err != nil {
+		return nil, err
+	}
+
+	if c.config, err = config.Load(configFs, c.dir, c.flags, c.logger); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Config returns the loaded CLI config
+func (c *Client) Config() *config.Config {
+	return c.config
+}
+
+// Settings returns the effective values of all config keys with their origins
+func (c *Client) Settings() []config.Setting {
+	return c.config.Settings()
+}
+
+// Describe returns the effective config as the nested maps of the config keys
+func (c *Client) Describe() map[string]interface{} {
+	return c.config.Describe()
+}
+
+// Validate checks the loaded CLI config, see 'config.Config.Validate'
+func (c *Client) Validate() ([]config.ValidationIssue, error) {
+	fss, err := c.rootFs()
+	if err != nil {
+		return nil, err
+	}
+
+	return c.config.Validate(fss), nil
+}
+
+// VendorPull pulls the sources of the component of the type ('terraform' or 'helmfile') into the component folder
+func (c *Client) VendorPull(component string, componentType string, dryRun bool, allowSecrets bool) error {
+	v, repoFs, err := c.vender()
+	if err != nil {
+		return err
+	}
+
+	componentConfig, componentPath, err := c.config.ReadComponentFile(repoFs, component, componentType)
+	if err != nil {
+		return err
+	}
+
+	return v.ExecuteComponentVendorCommand(componentConfig.Spec, component, componentPath, dryRun, allowSecrets, "pull")
+}
+
+// VendorDiff writes the unified diff of the changes that 'VendorPull' would make in the component folder
+func (c *Client) VendorDiff(component string, componentType string, w io.Writer) error {
+	v, repoFs, err := c.vender()
+	if err != nil {
+		return err
+	}
+
+	componentConfig, componentPath, err := c.config.ReadComponentFile(repoFs, component, componentType)
+	if err != nil {
+		return err
+	}
+
+	return v.DiffComponentVendorFiles(componentConfig.Spec, component, componentPath, w)
+}
+
+// VendorListFiles downloads the component source and decides for each file if it is vendored
+func (c *Client) VendorListFiles(component string, componentType string) ([]vender.PathDecision, error) {
+	v, repoFs, err := c.vender()
+	if err != nil {
+		return nil, err
+	}
+
+	componentConfig, _, err := c.config.ReadComponentFile(repoFs, component, componentType)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.ListComponentVendorFiles(componentConfig.Spec, component)
+}
+
+// VendorAdd creates the 'component.yaml' vendor config file for the source in the component folder, and pulls the component.
+// Returns the vendored files
+func (c *Client) VendorAdd(component string, componentType string, source config.VendorComponentSource, allowSecrets bool) ([]string, error) {
+	v, _, err := c.vender()
+	if err != nil {
+		return nil, err
+	}
+
+	componentPath, err := c.config.ComponentPath(component, componentType)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.ExecuteComponentVendorAddCommand(source, component, componentPath, allowSecrets)
+}
+
+// VendorRemove removes the vendored files, the vendoring state and the 'component.yaml' vendor config file from the component folder.
+// The modified vendored files are kept, unless 'force' is true
+func (c *Client) VendorRemove(component string, componentType string, dryRun bool, force bool) error {
+	v, repoFs, err := c.vender()
+	if err != nil {
+		return err
+	}
+
+	// Only the vendored components (with a valid 'component.yaml') are removed
+	_, componentPath, err := c.config.ReadComponentFile(repoFs, component, componentType)
+	if err != nil {
+		return err
+	}
+
+	return v.ExecuteComponentVendorRemoveCommand(component, componentPath, dryRun, force)
+}
+
+// VendorStack executes the vendor command ('pull' or 'diff') for all components of the stack
+func (c *Client) VendorStack(stack string, dryRun bool, vendorCommand string) error {
+	v, _, err := c.vender()
+	if err != nil {
+		return err
+	}
+
+	return v.ExecuteStackVendorCommand(stack, dryRun, vendorCommand)
+}
+
+// Init creates a new project in the folder (relative to the client folder), see 'scaffold.Init'. Returns the created files
+func (c *Client) Init(dir string, options scaffold.Options) ([]string, error) {
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(c.dir, dir)
+	}
+
+	projectFs, err := c.dirFs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return scaffold.Init(c.logger.With("dir", dir), projectFs, options)
+}
+
+// vender returns the vender for the repo, and the repo file system with 'base_path' as the base folder
+func (c *Client) vender() (*vender.Vender, fs.FileSystem, error) {
+	repoFs, err := c.dirFs(c.config.BasePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return vender.New(c.ctx, repoFs, c.config.Vendor, c.logger), repoFs, nil
+}
+
+// dirFs returns the file system with the absolute folder as the base folder
+func (c *Client) dirFs(dir string) (fs.FileSystem, error) {
+	if c.fs == nil {
+		return fs.FromDir(dir)
+	}
+	return c.fs.Sub(strings.TrimPrefix(filepath.ToSlash(dir), "/"))
+}
+
+// rootFs returns the file system with the absolute paths
+func (c *Client) rootFs() (fs.FileSystem, error) {
+	if c.fs == nil {
+		return fs.FromDir("")
+	}
+	return c.fs, nil
+}
@@ -0,0 +1,84 @@
+package homectl_test
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/home-sol/homectl/internal/testutil"
+	"github.com/home-sol/homectl/pkg/config"
+	"github.com/home-sol/homectl/pkg/fs"
+	"github.com/home-sol/homectl/pkg/homectl"
+	"github.com/home-sol/homectl/pkg/scaffold"
+)
+
+func TestClients(t *testing.T) {
+	t.Setenv(config.ConfigFileEnvName, "")
+	t.Setenv(config.ProfileEnvName, "")
+	t.Setenv("XDG_CONFIG_HOME", "/xdg")
+
+	server := testutil.NewArchiveServer(t, map[string]string{"main.tf": "# main\n"})
+
+	fss := fs.NewMemFileSystem()
+	require.NoError(t, fss.WriteFile("cabin/homectl.yaml", []byte("components:\n  terraform:\n    base_path: \"infra\"\n"), 0644))
+	require.NoError(t, fss.WriteFile("town/homectl.yaml", []byte("vendor:\n  policy:\n    allowed_hosts: [\"example.com\"]\n"), 0644))
+
+	cabin, err := homectl.New(homectl.WithFileSystem(fss), homectl.WithDir("/cabin"))
+	require.NoError(t, err)
+
+	flags := pflag.NewFlagSet("homectl", pflag.ContinueOnError)
+	config.AddFlags(flags)
+	require.NoError(t, flags.Parse([]string{"--stacks-dir", "sites"}))
+
+	town, err := homectl.New(homectl.WithFileSystem(fss), homectl.WithDir("/town/components"), homectl.WithFlags(flags))
+	require.NoError(t, err)
+
+	// Each client has its own config
+	assert.Equal(t, "/cabin", cabin.Config().BasePath)
+	assert.Equal(t, "infra", cabin.Config().Components.Terraform.BasePath)
+	assert.Equal(t, "stacks", cabin.Config().Stacks.BasePath)
+	assert.Equal(t, "/town", town.Config().BasePath)
+	assert.Equal(t, "components/terraform", town.Config().Components.Terraform.BasePath)
+	assert.Equal(t, "sites", town.Describe()["stacks"].(map[string]interface{})["base_path"])
+
+	source := config.VendorComponentSource{Uri: server.URL + "/source.tar.gz"}
+
+	files, err := cabin.VendorAdd("vpc", "terraform", source, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"main.tf"}, files)
+	assert.True(t, fss.FileExists("cabin/infra/vpc/main.tf"))
+
+	// The vendor policy of the other client does not allow the source
+	_, err = town.VendorAdd("vpc", "terraform", source, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is not allowed by the vendor policy")
+	assert.False(t, fss.FileExists("town/components/terraform/vpc/component.yaml"))
+
+	// The component added by the first client is pulled and removed using its config
+	require.NoError(t, cabin.VendorPull("vpc", "terraform", false, false))
+	require.NoError(t, cabin.VendorRemove("vpc", "terraform", false, false))
+	assert.False(t, fss.FileExists("cabin/infra/vpc/main.tf"))
+}
+
+func TestClientWithoutConfig(t *testing.T) {
+	t.Setenv(config.ConfigFileEnvName, "")
+	t.Setenv(config.ProfileEnvName, "")
+	t.Setenv("XDG_CONFIG_HOME", "/xdg")
+
+	fss := fs.NewMemFileSystem()
+	require.NoError(t, fss.WriteFile("cabin/homectl.yaml", []byte("components: [\n"), 0644))
+
+	_, err := homectl.New(homectl.WithFileSystem(fss), homectl.WithDir("/cabin"))
+	require.Error(t, err)
+
+	// A new project is created next to the invalid one
+	client, err := homectl.New(homectl.WithFileSystem(fss), homectl.WithDir("/cabin"), homectl.WithoutConfig())
+	require.NoError(t, err)
+	assert.Nil(t, client.Config())
+
+	_, err = client.Init("../town", scaffold.DefaultOptions())
+	require.NoError(t, err)
+	assert.True(t, fss.FileExists("town/homectl.yaml"))
+}
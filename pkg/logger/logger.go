@@ -6,11 +6,8 @@ import (
 	"go.uber.org/zap"
 )
 
-var (
-	Logger *zap.SugaredLogger
-)
-
-func InitLogger() error {
+// New returns the CLI logger. The debug logs are enabled with the 'HOMECTL_LOGS_VERBOSE' ENV var
+func New() (*zap.SugaredLogger, error) {
 	var options []zap.Option
 	if _, found := os.LookupEnv("HOMECTL_LOGS_VERBOSE"); found {
 		options = append(options, zap.IncreaseLevel(zap.DebugLevel))
@@ -18,10 +15,8 @@ func InitLogger() error {
 
 	logger, err := zap.NewProduction(options...)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	Logger = logger.Sugar()
-
-	return nil
+	return logger.Sugar(), nil
 }
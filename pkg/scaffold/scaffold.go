@@ -0,0 +1,432 @@
+// Package scaffold creates the folders and the starter files of a new homectl project ('homectl init')
+package scaffold
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"go.uber.org/zap"
+
+	"github.com/home-sol/homectl/pkg/config"
+	"github.com/home-sol/homectl/pkg/fs"
+	"github.com/home-sol/homectl/pkg/vender"
+)
+
+// nameRegexp matches the tenant, environment and stage names, and each segment of the component name
+var nameRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Options are the settings of the new project
+type Options struct {
+	// The folders of the project, relative to the project folder
+	TerraformDir string
+	HelmfileDir  string
+	StacksDir    string
+	WorkflowsDir string
+	// The vars of the starter stack, the stack name is '<tenant>-<environment>-<stage>'
+	Tenant      string
+	Environment string
+	Stage       string
+	// The starter Terraform component (e.g. 'label' or 'infra/label') with its 'component.yaml' vendor config file
+	Component string
+	Source    config.VendorComponentSource
+	// Overwrite the existing files
+	Force bool
+}
+
+// DefaultOptions returns the options of the project with the default folders, and the 'label' starter component vendored
+// from 'terraform-null-label'
+func DefaultOptions() Options {
+	return Options{
+		TerraformDir: "components/terraform",
+		HelmfileDir:  "components/helmfile",
+		StacksDir:    "stacks",
+		WorkflowsDir: "workflows",
+		Tenant:       "home",
+		Environment:  "main",
+		Stage:        "prod",
+		Component:    "label",
+		Source: config.VendorComponentSource{
+			Uri:           "github.com/cloudposse/terraform-null-label.git?ref={{.Version}}",
+			Version:       "0.25.0",
+			IncludedPaths: []string{"*.tf", "*.md"},
+			ExcludedPaths: []string{"examples/", "test/"},
+		},
+	}
+}
+
+// projectFileTemplate is the 'homectl.yaml' project config with the documented defaults
+var projectFileTemplate = template.Must(template.New("homectl.yaml").Funcs(template.FuncMap{
+	"quote": strconv.Quote,
+}).Parse(`# Every key can be overridden using the ` + "`HOMECTL_<KEY>`" + ` ENV var, where the nested keys are joined with ` + "`_`" + `
+# (e.g. ` + "`HOMECTL_VENDOR_HTTP_PROXY`" + ` for ` + "`vendor.http.proxy`" + `). The ENV vars override the config files,
+# lists are comma-separated values strings, and maps are comma-separated ` + "`name=value`" + ` pairs
+
+# The project config is found in the current folder or its closest parent, or specified using the ` + "`--config`" + ` command-line argument
+# or ` + "`HOMECTL_CONFIG`" + ` ENV var. The user config is read from ` + "`~/.homectl/homectl.yaml`" + ` and ` + "`$XDG_CONFIG_HOME/homectl/homectl.yaml`" + `
+# Relative ` + "`base_path`" + ` is resolved against the folder of the config file that sets it. Defaults to the folder of the project config
+base_path: "."
+
+components:
+  terraform:
+    # Can also be set using ` + "`HOMECTL_COMPONENTS_TERRAFORM_BASE_PATH`" + ` ENV var, or ` + "`--terraform-dir`" + ` command-line argument
+    # Supports both absolute and relative paths
+    base_path: {{quote .TerraformDir}}
+    # Can also be set using ` + "`HOMECTL_COMPONENTS_TERRAFORM_APPLY_AUTO_APPROVE`" + ` ENV var
+    apply_auto_approve: false
+    # Can also be set using ` + "`HOMECTL_COMPONENTS_TERRAFORM_DEPLOY_RUN_INIT`" + ` ENV var, or ` + "`--deploy-run-init`" + ` command-line argument
+    deploy_run_init: true
+    # Can also be set using ` + "`HOMECTL_COMPONENTS_TERRAFORM_INIT_RUN_RECONFIGURE`" + ` ENV var, or ` + "`--init-run-reconfigure`" + ` command-line argument
+    init_run_reconfigure: true
+    # Can also be set using ` + "`HOMECTL_COMPONENTS_TERRAFORM_AUTO_GENERATE_BACKEND_FILE`" + ` ENV var, or ` + "`--auto-generate-backend-file`" + ` command-line argument
+    auto_generate_backend_file: false
+  helmfile:
+    # Can also be set using ` + "`HOMECTL_COMPONENTS_HELMFILE_BASE_PATH`" + ` ENV var, or ` + "`--helmfile-dir`" + ` command-line argument
+    # Supports both absolute and relative paths
+    base_path: {{quote .HelmfileDir}}
+    # Can also be set using ` + "`HOMECTL_COMPONENTS_HELMFILE_KUBECONFIG_PATH`" + ` ENV var
+    kubeconfig_path: "/dev/shm"
+    # Can also be set using ` + "`HOMECTL_COMPONENTS_HELMFILE_HELM_AWS_PROFILE_PATTERN`" + ` ENV var
+    helm_aws_profile_pattern: "{namespace}-{tenant}-gbl-{stage}-helm"
+    # Can also be set using ` + "`HOMECTL_COMPONENTS_HELMFILE_CLUSTER_NAME_PATTERN`" + ` ENV var
+    cluster_name_pattern: "{namespace}-{tenant}-{environment}-{stage}-eks-cluster"
+
+stacks:
+  # Can also be set using ` + "`HOMECTL_STACKS_BASE_PATH`" + ` ENV var, or ` + "`--config-dir`" + ` and ` + "`--stacks-dir`" + ` command-line arguments
+  # Supports both absolute and relative paths
+  base_path: {{quote .StacksDir}}
+  # Can also be set using ` + "`HOMECTL_STACKS_INCLUDED_PATHS`" + ` ENV var (comma-separated values string)
+  included_paths:
+    - "**/*"
+  # The files imported by the stacks (e.g. the globals and the catalog of the component defaults) are not stacks themselves
+  # Can also be set using ` + "`HOMECTL_STACKS_EXCLUDED_PATHS`" + ` ENV var (comma-separated values string)
+  excluded_paths:
+    - "globals/**/*"
+    - "catalog/**/*"
+    - "**/*globals*"
+  # Can also be set using ` + "`HOMECTL_STACKS_NAME_PATTERN`" + ` ENV var
+  name_pattern: "{tenant}-{environment}-{stage}"
+
+workflows:
+  # Can also be set using ` + "`HOMECTL_WORKFLOWS_BASE_PATH`" + ` ENV var, or ` + "`--workflows-dir`" + ` command-line arguments
+  # Supports both absolute and relative paths
+  base_path: {{quote .WorkflowsDir}}
+
+logs:
+  verbose: false
+  colors: true
+
+vendor:
+  # Terraform module registry used by the sources with 'type: terraform-registry'
+  # https://www.terraform.io/internals/module-registry-protocol
+  terraform_registry:
+    # Host of the registry. Sources can use a different registry by prefixing the module address with the host
+    # Can also be set using ` + "`HOMECTL_VENDOR_TERRAFORM_REGISTRY_HOST`" + ` ENV var
+    host: "registry.terraform.io"
+    # API token for private registries. If not specified, the token is read from the ` + "`TF_TOKEN_<host>`" + ` ENV var
+    # or from the ` + "`~/.terraform.d/credentials.tfrc.json`" + ` file created by ` + "`terraform login`" + `
+    # Can also be set using ` + "`HOMECTL_VENDOR_TERRAFORM_REGISTRY_TOKEN`" + ` ENV var
+    token: ""
+  git:
+    # Git sources are fetched into bare repositories (one per remote) in this folder. Each repository is locked while it's used.
+    # Defaults to the 'homectl/git' folder in the user's cache dir (e.g. ` + "`~/.cache/homectl/git`" + `)
+    # Can also be set using ` + "`HOMECTL_VENDOR_GIT_CACHE_DIR`" + ` ENV var
+    cache_dir: ""
+  # HTTP settings for the downloads of all source types (go-getter, OCI, Helm, Terraform registry) and for git over https
+  # They are not used by the 's3' and 'gcs' sources (they use the AWS and Google Cloud SDK settings), and by the git sources
+  # with the go-getter options not supported by the git cache (e.g. 'sshkey')
+  http:
+    # Proxy for the 'http' and 'https' downloads. If not specified, the 'HTTP_PROXY', 'HTTPS_PROXY' and 'NO_PROXY' ENV vars are used
+    proxy: ""
+    # Proxy for the 'https' downloads, if it's different from 'proxy'
+    https_proxy: ""
+    # Hosts, domains (e.g. '.example.com') and CIDRs that are accessed without the proxy
+    no_proxy: []
+    # PEM files with the additional CA certificates trusted besides the system CAs (e.g. the corporate TLS inspection CA)
+    ca_bundles: []
+    # PEM files with the client certificate and its key for the servers that require mutual TLS
+    client_cert: ""
+    client_key: ""
+  oci:
+    # Registries (e.g. 'registry.lan' or 'registry.lan:5000') accessed using plain 'http' instead of 'https'.
+    # The registries on the local host always use plain 'http'
+    insecure_registries: []
+  # Source aliases referenced in 'uri' of the sources and mixins as '<alias>://<path>' (the alias names are case-insensitive)
+  # The alias is a Golang template where '{{"{{.Path}}"}}' is replaced with the '<path>' and '{{"{{.Version}}"}}' with the 'version'
+  # of the source or mixin. Moving the components to a fork only needs changing the alias
+  # Can also be set using ` + "`HOMECTL_VENDOR_ALIASES`" + ` ENV var (comma-separated ` + "`name=value`" + ` pairs)
+  aliases: {}
+  #  cp-components: "github.com/cloudposse/terraform-aws-components.git//modules/{{"{{.Path}}"}}?ref={{"{{.Version}}"}}"
+  # Restrictions for the sources and mixins. The sources are checked before any network access,
+  # and a violation fails the command with the rule that failed. Empty lists don't restrict anything
+  # The HTTP redirects and the sources from the 'X-Terraform-Get' header are checked the same way (e.g. the OCI registries
+  # that redirect the downloads to a storage host need that host in 'allowed_hosts')
+  policy:
+    # Allowed URL schemes and go-getter forced getters (e.g. 'git' in 'git::https://...'), e.g. 'https', 'git', 'ssh', 'oci', 's3'
+    # The 'oci://' sources need both 'oci' and the scheme of the registry ('https', or 'http' for the registries on the local host and in 'vendor.oci.insecure_registries')
+    allowed_schemes: []
+    # Allowed hosts, wildcards are supported (e.g. '*.example.com')
+    allowed_hosts: []
+    # Allowed prefixes of the 'uri' as specified in 'component.yaml' or as resolved by go-getter (e.g. 'https://github.com/cloudposse/')
+    allowed_uri_prefixes: []
+    # Deny the sources downloaded over plain 'http'
+    deny_http: false
+    # Maximum size in bytes of the downloaded archives and of the unpacked source (0 means no limit)
+    max_archive_size: 0
+    # Maximum number of files in the unpacked source (0 means no limit)
+    max_file_count: 0
+  # Scan the vendored files for known secret patterns before they are written into the component folder.
+  # 'homectl vendor pull' fails listing the findings, unless '--allow-secrets' is specified
+  secret_scan:
+    enabled: false
+    # gitignore-style patterns of the files that are not scanned (e.g. test fixtures)
+    allowed_paths: []
+    #  - "testdata/"
+  # 'vendor pull', 'diff', 'add' and 'remove' lock the component, so concurrent runs on the same component wait for each other
+  lock:
+    # Folder of the lock files, relative to 'base_path' ('homectl init' adds the default folder to '.gitignore')
+    dir: ".homectl/locks"
+    # How long to wait for the lock held by another process before failing (e.g. '30s', '5m', '0s' to fail immediately)
+    timeout: 30s
+
+# Config files or globs (e.g. ` + "`sites/*.yaml`" + `, ` + "`shared/**/*.yaml`" + `) merged before this file, in order, relative to this file's folder
+# The imported files can import other files. The keys set in this file override the imported ones
+import: []
+
+# Named profiles overlaying the config, selected using the ` + "`--profile`" + ` command-line argument or ` + "`HOMECTL_PROFILE`" + ` ENV var
+# The ENV vars and command-line arguments override the profiles
+profiles: {}
+#  cabin:
+#    stacks:
+#      base_path: "stacks/cabin"
+`))
+
+// stackFileTemplate is the starter stack deploying the starter component
+var stackFileTemplate = template.Must(template.New("stack").Funcs(template.FuncMap{
+	"quote": strconv.Quote,
+}).Parse(`# '{{.Stack}}' stack
+# The stack name is built from the 'vars' using 'stacks.name_pattern' in 'homectl.yaml'
+
+import:
+  - "globals/globals"
+  - {{quote .Catalog}}
+
+vars:
+  tenant: {{quote .Options.Tenant}}
+  environment: {{quote .Options.Environment}}
+  stage: {{quote .Options.Stage}}
+
+components:
+  terraform:
+    {{quote .Options.Component}}:
+      vars: {}
+`))
+
+// catalogFileTemplate is the defaults of the starter component, imported by the stacks
+var catalogFileTemplate = template.Must(template.New("catalog").Funcs(template.FuncMap{
+	"quote": strconv.Quote,
+}).Parse(`# Defaults of the '{{.Options.Component}}' component, imported by the stacks that deploy it
+# The catalog files are excluded from the stacks by 'stacks.excluded_paths' in 'homectl.yaml'
+
+components:
+  terraform:
+    {{quote .Options.Component}}:
+      vars:
+        enabled: true
+`))
+
+const (
+	gitignoreFile = ".gitignore"
+	// lockDirPattern ignores the default 'vendor.lock.dir' folder of the project
+	lockDirPattern = "/.homectl/locks/"
+)
+
+// globalsFile are the global settings imported by all stacks
+const globalsFile = `# Global settings imported by all stacks
+# The globals files are excluded from the stacks by 'stacks.excluded_paths' in 'homectl.yaml'
+
+vars: {}
+`
+
+// file is the file created by 'Init'
+type file struct {
+	path    string
+	content []byte
+}
+
+// Init creates 'homectl.yaml', the components, stacks and workflows folders, the starter component with its 'component.yaml'
+// vendor config file, and the starter stack in the project file system, and adds the lock folder to '.gitignore'.
+// The existing files are only overwritten if 'Force' is set, otherwise nothing is written. Returns the created files
+func Init(l *zap.SugaredLogger, fss fs.FileSystem, options Options) ([]string, error) {
+	if err := checkOptions(&options); err != nil {
+		return nil, err
+	}
+
+	files, err := projectFiles(options)
+	if err != nil {
+		return nil, err
+	}
+
+	// All files are checked before writing anything, so the project is not left half-created
+	if !options.Force {
+		var existing []string
+		for _, f := range files {
+			if fss.FileExists(f.path) {
+				existing = append(existing, "'"+f.path+"'")
+			}
+		}
+		if len(existing) > 0 {
+			return nil, fmt.Errorf("the files already exist: %s, use '--force' to overwrite them", strings.Join(existing, ", "))
+		}
+	}
+
+	var created []string
+	for _, f := range files {
+		l.With("file", f.path).Debug("Writing the file")
+		if err = fss.WriteFile(f.path, f.content, 0644); err != nil {
+			return created, err
+		}
+		created = append(created, f.path)
+	}
+
+	updated, err := ignoreLockDir(fss)
+	if err != nil {
+		return created, err
+	}
+	if updated {
+		l.With("file", gitignoreFile).Debug("Added the lock folder to the file")
+		created = append(created, gitignoreFile)
+	}
+
+	return created, nil
+}
+
+// ignoreLockDir adds the 'vendor.lock.dir' folder to '.gitignore', creating the file if it doesn't exist.
+// The existing '.gitignore' is appended to, not overwritten. Returns false if the folder is already ignored
+func ignoreLockDir(fss fs.FileSystem) (bool, error) {
+	var content []byte
+	if fss.FileExists(gitignoreFile) {
+		var err error
+		if content, err = fss.ReadFile(gitignoreFile); err != nil {
+			return false, err
+		}
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.TrimSpace(line) == lockDirPattern {
+			return false, nil
+		}
+	}
+
+	if len(content) > 0 && !bytes.HasSuffix(content, []byte("\n")) {
+		content = append(content, '\n')
+	}
+	content = append(content, []byte("# Lock files of 'homectl vendor' ('vendor.lock.dir' in 'homectl.yaml')\n"+lockDirPattern+"\n")...)
+
+	return true, fss.WriteFile(gitignoreFile, content, 0644)
+}
+
+// checkOptions checks the names and cleans the folders of the options
+func checkOptions(options *Options) error {
+	dirs := []struct {
+		name string
+		dir  *string
+	}{
+		{"terraform", &options.TerraformDir},
+		{"helmfile", &options.HelmfileDir},
+		{"stacks", &options.StacksDir},
+		{"workflows", &options.WorkflowsDir},
+	}
+
+	seen := map[string]string{}
+	for _, d := range dirs {
+		dir := filepath.ToSlash(*d.dir)
+		if dir == "" {
+			return fmt.Errorf("the %s folder must be specified", d.name)
+		}
+		if filepath.IsAbs(*d.dir) || path.IsAbs(dir) {
+			return fmt.Errorf("the %s folder '%s' must be relative to the project folder", d.name, *d.dir)
+		}
+		dir = path.Clean(dir)
+		if dir == "." || dir == ".." || strings.HasPrefix(dir, "../") {
+			return fmt.Errorf("the %s folder '%s' must be inside the project folder", d.name, *d.dir)
+		}
+		if other, ok := seen[dir]; ok {
+			return fmt.Errorf("the %s folder '%s' is the same as the %s folder", d.name, *d.dir, other)
+		}
+		seen[dir] = d.name
+		*d.dir = dir
+	}
+
+	names := []struct {
+		name  string
+		value string
+	}{
+		{"tenant", options.Tenant},
+		{"environment", options.Environment},
+		{"stage", options.Stage},
+	}
+	for _, n := range names {
+		if !nameRegexp.MatchString(n.value) {
+			return fmt.Errorf("invalid %s '%s', it must start with a letter or a digit and only contain letters, digits, '.', '-' and '_'", n.name, n.value)
+		}
+	}
+
+	for _, segment := range strings.Split(options.Component, "/") {
+		if !nameRegexp.MatchString(segment) || segment == ".." {
+			return fmt.Errorf("invalid component '%s', it must be a name or a path of names (e.g. 'infra/label') made of letters, digits, '.', '-' and '_'", options.Component)
+		}
+	}
+
+	if options.Source.Uri == "" {
+		return fmt.Errorf("the source 'uri' of the '%s' component must be specified", options.Component)
+	}
+
+	return nil
+}
+
+// projectFiles returns the files of the project in the order they are created
+func projectFiles(options Options) ([]file, error) {
+	stack := fmt.Sprintf("%s-%s-%s", options.Tenant, options.Environment, options.Stage)
+	data := struct {
+		Options Options
+		Stack   string
+		Catalog string
+	}{
+		Options: options,
+		Stack:   stack,
+		Catalog: path.Join("catalog", options.Component),
+	}
+
+	var projectFile, stackFile, catalogFile bytes.Buffer
+	if err := projectFileTemplate.Execute(&projectFile, options); err != nil {
+		return nil, err
+	}
+	if err := stackFileTemplate.Execute(&stackFile, data); err != nil {
+		return nil, err
+	}
+	if err := catalogFileTemplate.Execute(&catalogFile, data); err != nil {
+		return nil, err
+	}
+
+	componentFile, err := vender.ComponentFile(options.Component, options.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	// The empty folders are kept in git with '.gitkeep'
+	return []file{
+		{path: config.ConfigFileName, content: projectFile.Bytes()},
+		{path: path.Join(options.TerraformDir, options.Component, "component.yaml"), content: componentFile},
+		{path: path.Join(options.HelmfileDir, ".gitkeep")},
+		{path: path.Join(options.StacksDir, stack+".yaml"), content: stackFile.Bytes()},
+		{path: path.Join(options.StacksDir, "catalog", options.Component+".yaml"), content: catalogFile.Bytes()},
+		{path: path.Join(options.StacksDir, "globals", "globals.yaml"), content: []byte(globalsFile)},
+		{path: path.Join(options.WorkflowsDir, ".gitkeep")},
+	}, nil
+}
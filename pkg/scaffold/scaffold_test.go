@@ -0,0 +1,131 @@
+package scaffold_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
+
+	"github.com/home-sol/homectl/pkg/config"
+	"github.com/home-sol/homectl/pkg/fs"
+	"github.com/home-sol/homectl/pkg/scaffold"
+)
+
+func TestInit(t *testing.T) {
+	t.Setenv(config.ConfigFileEnvName, "")
+	t.Setenv(config.ProfileEnvName, "")
+	t.Setenv("XDG_CONFIG_HOME", "/xdg")
+
+	fss := fs.NewMemFileSystem()
+	projectFs, err := fss.Sub("site")
+	require.NoError(t, err)
+
+	options := scaffold.DefaultOptions()
+	options.TerraformDir = "infra/terraform"
+	options.Component = "infra/label"
+
+	files, err := scaffold.Init(zap.NewNop().Sugar(), projectFs, options)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"homectl.yaml",
+		"infra/terraform/infra/label/component.yaml",
+		"components/helmfile/.gitkeep",
+		"stacks/home-main-prod.yaml",
+		"stacks/catalog/infra/label.yaml",
+		"stacks/globals/globals.yaml",
+		"workflows/.gitkeep",
+		".gitignore",
+	}, files)
+
+	// The new project loads and validates without any issues
+	c, err := config.Load(fss, "/site", nil, zap.NewNop().Sugar())
+	require.NoError(t, err)
+	assert.Equal(t, "/site", c.BasePath)
+	assert.Equal(t, "infra/terraform", c.Components.Terraform.BasePath)
+	assert.Equal(t, "{tenant}-{environment}-{stage}", c.Stacks.NamePattern)
+	assert.Empty(t, c.Validate(fss))
+
+	componentConfig, componentPath, err := c.ReadComponentFile(projectFs, "infra/label", "terraform")
+	require.NoError(t, err)
+	assert.Equal(t, "infra/terraform/infra/label", componentPath)
+	assert.Equal(t, options.Source.Uri, componentConfig.Spec.Source.Uri)
+	assert.Equal(t, "0.25.0", componentConfig.Spec.Source.Version)
+
+	content, err := projectFs.ReadFile("stacks/home-main-prod.yaml")
+	require.NoError(t, err)
+	var stack struct {
+		Import     []string                                     `yaml:"import"`
+		Vars       map[string]string                            `yaml:"vars"`
+		Components map[string]map[string]map[string]interface{} `yaml:"components"`
+	}
+	require.NoError(t, yaml.Unmarshal(content, &stack))
+	assert.Equal(t, []string{"globals/globals", "catalog/infra/label"}, stack.Import)
+	assert.Equal(t, map[string]string{"tenant": "home", "environment": "main", "stage": "prod"}, stack.Vars)
+	assert.Contains(t, stack.Components["terraform"], "infra/label")
+}
+
+func TestInitExistingFiles(t *testing.T) {
+	fss := fs.NewMemFileSystem()
+	require.NoError(t, fss.WriteFile("homectl.yaml", []byte("# mine\n"), 0644))
+	require.NoError(t, fss.WriteFile("stacks/globals/globals.yaml", []byte("# mine\n"), 0644))
+	require.NoError(t, fss.WriteFile(".gitignore", []byte("*.tfstate"), 0644))
+
+	// Nothing is written if any file exists
+	_, err := scaffold.Init(zap.NewNop().Sugar(), fss, scaffold.DefaultOptions())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "'homectl.yaml', 'stacks/globals/globals.yaml'")
+	assert.Contains(t, err.Error(), "--force")
+	assert.False(t, fss.FileExists("stacks/home-main-prod.yaml"))
+
+	content, err := fss.ReadFile("homectl.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, "# mine\n", string(content))
+
+	options := scaffold.DefaultOptions()
+	options.Force = true
+	files, err := scaffold.Init(zap.NewNop().Sugar(), fss, options)
+	require.NoError(t, err)
+	assert.Len(t, files, 8)
+
+	content, err = fss.ReadFile("homectl.yaml")
+	require.NoError(t, err)
+	assert.Contains(t, string(content), `base_path: "components/terraform"`)
+
+	// The existing '.gitignore' is appended to, and the lock folder is only added once
+	files, err = scaffold.Init(zap.NewNop().Sugar(), fss, options)
+	require.NoError(t, err)
+	assert.NotContains(t, files, ".gitignore")
+
+	content, err = fss.ReadFile(".gitignore")
+	require.NoError(t, err)
+	assert.Equal(t, "*.tfstate\n# Lock files of 'homectl vendor' ('vendor.lock.dir' in 'homectl.yaml')\n/.homectl/locks/\n", string(content))
+}
+
+func TestInitInvalidOptions(t *testing.T) {
+	tests := map[string]struct {
+		update func(*scaffold.Options)
+		err    string
+	}{
+		"absolute folder":   {func(o *scaffold.Options) { o.StacksDir = "/stacks" }, "must be relative to the project folder"},
+		"outside folder":    {func(o *scaffold.Options) { o.WorkflowsDir = "../workflows" }, "must be inside the project folder"},
+		"same folders":      {func(o *scaffold.Options) { o.HelmfileDir = "components/terraform/" }, "is the same as the terraform folder"},
+		"empty tenant":      {func(o *scaffold.Options) { o.Tenant = "" }, "invalid tenant ''"},
+		"invalid stage":     {func(o *scaffold.Options) { o.Stage = "prod/eu" }, "invalid stage 'prod/eu'"},
+		"invalid component": {func(o *scaffold.Options) { o.Component = "../label" }, "invalid component '../label'"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			fss := fs.NewMemFileSystem()
+			options := scaffold.DefaultOptions()
+			test.update(&options)
+
+			_, err := scaffold.Init(zap.NewNop().Sugar(), fss, options)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.err)
+			assert.False(t, fss.FileExists("homectl.yaml"))
+		})
+	}
+}
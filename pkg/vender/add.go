@@ -0,0 +1,294 @@
+package vender
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"text/template"
+
+	"github.com/home-sol/homectl/pkg/config"
+	"github.com/home-sol/homectl/pkg/fs"
+)
+
+const componentFileName = "component.yaml"
+
+// componentFileTemplate is the 'component.yaml' written by 'homectl vendor add'
+var componentFileTemplate = template.Must(template.New("component.yaml").Funcs(template.FuncMap{
+	"quote": strconv.Quote,
+}).Parse(`# '{{.Name}}' component vendoring config
+# 'homectl vendor pull' keeps the vendored files in '.vendor/base' in the component folder and merges the upstream changes
+# with the local modifications of the vendored files. Commit the '.vendor' folder to keep the merge base
+
+apiVersion: atmos/v1
+kind: ComponentVendorConfig
+metadata:
+  name: {{.Name}}-vendor-config
+  description: Source and mixins config for vendoring of '{{.Name}}' component
+spec:
+  source:
+    # 'type' selects how the source is pulled ('oci', 'helm' or 'terraform-registry').
+    # If 'type' is not specified, the source is pulled using go-getter https://github.com/hashicorp/go-getter
+{{- if .Source.Type}}
+    type: {{quote .Source.Type}}
+{{- end}}
+    # In 'uri', Golang templates are supported https://pkg.go.dev/text/template
+    # If 'version' is provided, '{{"{{.Version}}"}}' will be replaced with the 'version' value before pulling the files from 'uri'
+    uri: {{quote .Source.Uri}}
+{{- if .Source.Version}}
+    version: {{quote .Source.Version}}
+{{- end}}
+{{- if .Source.Chart}}
+    chart: {{quote .Source.Chart}}
+{{- end}}
+    # Only include the files that match the gitignore-style 'included_paths' patterns (all files if not specified),
+    # and exclude the files that match the 'excluded_paths' patterns
+    # Use 'homectl vendor ls-files --component <component>' to see which pattern decided if each file is vendored
+{{- if .Source.IncludedPaths}}
+    included_paths:
+{{- range .Source.IncludedPaths}}
+      - {{quote .}}
+{{- end}}
+{{- else}}
+    included_paths: []
+{{- end}}
+{{- if .Source.ExcludedPaths}}
+    excluded_paths:
+{{- range .Source.ExcludedPaths}}
+      - {{quote .}}
+{{- end}}
+{{- else}}
+    excluded_paths: []
+{{- end}}
+
+  # mixins override files from 'source' with the same 'filename'
+  # mixins are processed in the order they are declared in the list
+  mixins: []
+  #  - uri: https://raw.githubusercontent.com/cloudposse/terraform-null-label/0.25.0/exports/context.tf
+  #    filename: context.tf
+`))
+
+// ComponentFile returns the content of the 'component.yaml' vendor config file of the component for the source
+func ComponentFile(component string, source config.VendorComponentSource) ([]byte, error) {
+	var content bytes.Buffer
+	err := componentFileTemplate.Execute(&content, struct {
+		Name   string
+		Source config.VendorComponentSource
+	}{
+		Name:   path.Base(component),
+		Source: source,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return content.Bytes(), nil
+}
+
+// ExecuteComponentVendorAddCommand creates the component folder with the 'component.yaml' vendor config file for the source,
+// and pulls the component. Returns the vendored files
+func (v *Vender) ExecuteComponentVendorAddCommand(
+	source config.VendorComponentSource,
+	component string,
+	componentPath string,
+	allowSecrets bool,
+) ([]string, error) {
+
+	l := v.logger.With("component", component, "componentPath", componentPath)
+
+	unlock, err := v.lockComponent(l, componentPath)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	componentFile := path.Join(componentPath, componentFileName)
+	if v.fs.FileExists(componentFile) {
+		return nil, fmt.Errorf("vendor config file 'component.yaml' already exists in the '%s' folder", componentPath)
+	}
+
+	spec := config.VendorComponentSpec{Source: source}
+
+	// The source is validated before creating the component folder
+	if spec.Source.Uri == "" {
+		return nil, errors.New("'uri' must be specified")
+	}
+	if err := checkSourceType(spec.Source); err != nil {
+		return nil, err
+	}
+	if _, err := newPathMatcher(spec.Source); err != nil {
+		return nil, err
+	}
+
+	content, err := ComponentFile(component, source)
+	if err != nil {
+		return nil, err
+	}
+
+	// The component folder is removed if it is created here and the first pull fails
+	created := false
+	if _, err = v.fs.Stat(componentPath); errors.Is(err, os.ErrNotExist) {
+		created = true
+	}
+
+	l.Infof("Writing the vendor config file '%s'", componentFile)
+
+	if err = v.fs.WriteFile(componentFile, content, 0644); err != nil {
+		return nil, err
+	}
+
+	if err = v.pullComponent(spec, component, componentPath, false, allowSecrets); err != nil {
+		if created {
+			if err := v.fs.RemoveAll(componentPath); err != nil {
+				l.Error(err)
+			}
+		} else if err := v.fs.Remove(componentFile); err != nil {
+			l.Error(err)
+		}
+		return nil, err
+	}
+
+	files, err := listFiles(v.fs, path.Join(componentPath, vendorBaseDir))
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range files {
+		files[i] = filepath.ToSlash(files[i])
+	}
+
+	return files, nil
+}
+
+// ExecuteComponentVendorRemoveCommand removes the vendored files, the vendoring state and the 'component.yaml' vendor config
+// file from the component folder. The vendored files that were modified after the last pull are kept, unless 'force' is true.
+// The component folder is removed if no other files are left in it
+func (v *Vender) ExecuteComponentVendorRemoveCommand(
+	component string,
+	componentPath string,
+	dryRun bool,
+	force bool,
+) error {
+
+	l := v.logger.With("component", component, "componentPath", componentPath)
+
+	if !dryRun {
+		unlock, err := v.lockComponent(l, componentPath)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	componentFs, err := v.fs.Sub(componentPath)
+	if err != nil {
+		return err
+	}
+
+	var files []string
+	if _, err := componentFs.Stat(vendorBaseDir); err == nil {
+		if files, err = listFiles(componentFs, vendorBaseDir); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	} else {
+		l.Warn("The component has no vendoring state, only the vendor config file is removed")
+	}
+
+	if !force {
+		var unmodified []string
+		for _, file := range files {
+			modified, err := isModifiedFile(componentFs, file)
+			if err != nil {
+				return err
+			}
+			if modified {
+				l.Warnw("The vendored file was modified, it's kept (use '--force' to remove it)", "file", file)
+				continue
+			}
+			unmodified = append(unmodified, file)
+		}
+		files = unmodified
+	}
+
+	files = append(files, componentFileName)
+
+	for _, file := range files {
+		l.Infow("Removing the file", "file", file)
+
+		if dryRun {
+			continue
+		}
+
+		if err := componentFs.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	if dryRun {
+		return nil
+	}
+
+	if err = componentFs.RemoveAll(filepath.Dir(vendorBaseDir)); err != nil {
+		return err
+	}
+
+	// Remove the folders left empty, starting from the deepest
+	var dirs []string
+	err = componentFs.Walk(".", func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			dirs = append(dirs, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+
+	for _, dir := range dirs {
+		entries, err := componentFs.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		if len(entries) > 0 {
+			continue
+		}
+		if err = componentFs.Remove(dir); err != nil {
+			return err
+		}
+	}
+
+	if _, err = v.fs.Stat(componentPath); err == nil {
+		l.Warnf("The files that were not vendored are kept in the '%s' folder", componentPath)
+	}
+
+	return nil
+}
+
+// isModifiedFile checks if the vendored file (relative to the component folder) is different from its copy in the vendoring state.
+// The removed files are not modified
+func isModifiedFile(componentFs fs.FileSystem, file string) (bool, error) {
+	local, localLink, err := readEntry(componentFs, file)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	base, baseLink, err := readEntry(componentFs, filepath.Join(vendorBaseDir, file))
+	if err != nil {
+		return false, err
+	}
+
+	return !sameEntry(local, localLink, base, baseLink), nil
+}
@@ -0,0 +1,95 @@
+package vender_test
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/home-sol/homectl/internal/testutil"
+	"github.com/home-sol/homectl/pkg/config"
+	"github.com/home-sol/homectl/pkg/fs"
+)
+
+func TestVenderComponentAddAndRemove(t *testing.T) {
+	server := testutil.NewArchiveServer(t, map[string]string{
+		"main.tf":          "# main\n",
+		"modules/a/a.tf":   "# a\n",
+		"README.md":        "# foo\n",
+		"examples/main.tf": "# example\n",
+	})
+
+	cfg := &config.Config{}
+	cfg.BasePath = "."
+	cfg.Components.Terraform.BasePath = "components/terraform"
+
+	fss, err := fs.FromDir(t.TempDir())
+	require.NoError(t, err)
+
+	componentPath, err := cfg.ComponentPath("infra/foo", "terraform")
+	require.NoError(t, err)
+
+	source := config.VendorComponentSource{
+		Uri:           server.URL + "/source.tar.gz",
+		IncludedPaths: []string{"*.tf"},
+		ExcludedPaths: []string{"/examples/"},
+	}
+
+	files, err := newTestVender(fss, cfg.Vendor).ExecuteComponentVendorAddCommand(source, "infra/foo", componentPath, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"main.tf", "modules/a/a.tf"}, files)
+
+	// The written 'component.yaml' is a valid vendor config for the source
+	componentConfig, path, err := cfg.ReadComponentFile(fss, "infra/foo", "terraform")
+	require.NoError(t, err)
+	assert.Equal(t, componentPath, path)
+	assert.Equal(t, "foo-vendor-config", componentConfig.Metadata.Name)
+	assert.Equal(t, source.Uri, componentConfig.Spec.Source.Uri)
+	assert.Equal(t, source.IncludedPaths, componentConfig.Spec.Source.IncludedPaths)
+	assert.Equal(t, source.ExcludedPaths, componentConfig.Spec.Source.ExcludedPaths)
+
+	// The component can't be added twice
+	_, err = newTestVender(fss, cfg.Vendor).ExecuteComponentVendorAddCommand(source, "infra/foo", componentPath, false)
+	assert.Error(t, err)
+
+	// The files that were not vendored, and the modified vendored files are kept
+	require.NoError(t, ioutil.WriteFile(fss.GetRelativePath(componentPath+"/local.tf"), []byte("# local\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(fss.GetRelativePath(componentPath+"/modules/a/a.tf"), []byte("# a, modified\n"), 0644))
+
+	require.NoError(t, newTestVender(fss, cfg.Vendor).ExecuteComponentVendorRemoveCommand("infra/foo", componentPath, false, false))
+	assert.FileExists(t, fss.GetRelativePath(componentPath+"/local.tf"))
+	assert.FileExists(t, fss.GetRelativePath(componentPath+"/modules/a/a.tf"))
+	assert.NoFileExists(t, fss.GetRelativePath(componentPath+"/main.tf"))
+	assert.NoFileExists(t, fss.GetRelativePath(componentPath+"/component.yaml"))
+	assert.NoDirExists(t, fss.GetRelativePath(componentPath+"/.vendor"))
+
+	require.NoError(t, fss.Remove(componentPath+"/local.tf"))
+	require.NoError(t, fss.Remove(componentPath+"/modules/a/a.tf"))
+	require.NoError(t, newTestVender(fss, cfg.Vendor).ExecuteComponentVendorRemoveCommand("infra/foo", componentPath, false, false))
+	assert.NoDirExists(t, fss.GetRelativePath(componentPath))
+}
+
+func TestVenderComponentRemoveModifiedFiles(t *testing.T) {
+	server := testutil.NewArchiveServer(t, map[string]string{
+		"main.tf":      "# main\n",
+		"variables.tf": "# variables\n",
+	})
+
+	fss, err := fs.FromDir(t.TempDir())
+	require.NoError(t, err)
+
+	source := config.VendorComponentSource{Uri: server.URL + "/source.tar.gz"}
+	_, err = newTestVender(fss, config.Vendor{}).ExecuteComponentVendorAddCommand(source, "foo", "foo", false)
+	require.NoError(t, err)
+
+	require.NoError(t, fss.WriteFile("foo/main.tf", []byte("# main, modified\n"), 0644))
+
+	// The dry run removes nothing
+	require.NoError(t, newTestVender(fss, config.Vendor{}).ExecuteComponentVendorRemoveCommand("foo", "foo", true, true))
+	assert.True(t, fss.FileExists("foo/variables.tf"))
+
+	// The modified files are removed with 'force'
+	require.NoError(t, newTestVender(fss, config.Vendor{}).ExecuteComponentVendorRemoveCommand("foo", "foo", false, true))
+	assert.NoDirExists(t, fss.GetRelativePath("foo"))
+}
@@ -0,0 +1,76 @@
+package vender
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/hashicorp/go-getter"
+)
+
+// aliasDetector is a go-getter detector that expands the source aliases from 'vendor.aliases' in 'homectl.yaml'.
+// 'uri: <alias>://<path>' is replaced with the alias template, where '{{.Path}}' is the '<path>'
+// and '{{.Version}}' is the 'version' of the source or mixin
+type aliasDetector struct {
+	aliases map[string]string
+	version string
+}
+
+var _ getter.Detector = (*aliasDetector)(nil)
+
+// aliasTemplateData is the data for the alias templates
+type aliasTemplateData struct {
+	Path    string
+	Version string
+}
+
+func (d *aliasDetector) Detect(src string, _ string) (string, bool, error) {
+	i := strings.Index(src, "://")
+	if i <= 0 {
+		return "", false, nil
+	}
+
+	// The keys in 'homectl.yaml' are case-insensitive, same as the URL schemes
+	name := strings.ToLower(src[:i])
+	alias, ok := d.aliases[name]
+	if !ok {
+		return "", false, nil
+	}
+
+	t, err := template.New(fmt.Sprintf("alias-%s", name)).Option("missingkey=error").Parse(alias)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid source alias '%s' in 'vendor.aliases': %w", name, err)
+	}
+
+	var tpl bytes.Buffer
+	err = t.Execute(&tpl, aliasTemplateData{
+		Path:    strings.TrimPrefix(src[i+3:], "/"),
+		Version: d.version,
+	})
+	if err != nil {
+		return "", false, fmt.Errorf("invalid source alias '%s' in 'vendor.aliases': %w", name, err)
+	}
+
+	return tpl.String(), true, nil
+}
+
+// expandSourceAlias replaces the source alias in the 'uri' with the alias template.
+// The alias detector is called directly since 'getter.Detect' returns the URLs with a scheme (e.g. 'cp-components://vpc')
+// without calling the detectors
+func (v *Vender) expandSourceAlias(uri string, version string) (string, error) {
+	d := &aliasDetector{
+		aliases: v.config.Aliases,
+		version: version,
+	}
+
+	expanded, ok, err := d.Detect(uri, "")
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return uri, nil
+	}
+
+	return expanded, nil
+}
@@ -0,0 +1,42 @@
+package vender_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/home-sol/homectl/internal/testutil"
+	"github.com/home-sol/homectl/pkg/config"
+	"github.com/home-sol/homectl/pkg/fs"
+)
+
+func TestVenderComponentPullSourceAlias(t *testing.T) {
+	server := testutil.NewArchiveServer(t, map[string]string{
+		"main.tf": "# main\n",
+	})
+
+	vendorConfig := config.Vendor{}
+	vendorConfig.Aliases = map[string]string{
+		"test-archives": server.URL + "/{{.Path}}?version={{.Version}}",
+		"broken":        server.URL + "/{{.Unknown}}",
+	}
+
+	fss, err := fs.FromDir(t.TempDir())
+	require.NoError(t, err)
+
+	spec := config.VendorComponentSpec{
+		Source: config.VendorComponentSource{
+			Uri:     "test-archives://source.tar.gz",
+			Version: "1.0.0",
+		},
+	}
+
+	require.NoError(t, newTestVender(fss, vendorConfig).ExecuteComponentVendorCommand(spec, "test", "test", false, false, "pull"))
+	assert.FileExists(t, fss.GetRelativePath("test/main.tf"))
+
+	spec.Source.Uri = "broken://source.tar.gz"
+	err = newTestVender(fss, vendorConfig).ExecuteComponentVendorCommand(spec, "test", "test", false, false, "pull")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid source alias 'broken'")
+}
@@ -0,0 +1,68 @@
+package vender
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/home-sol/homectl/pkg/fs"
+)
+
+// extractTarGz extracts a gzip-compressed tar archive into the 'dst' folder
+func extractTarGz(l *zap.SugaredLogger, r io.Reader, dst fs.FileSystem, stripComponents int) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	return extractTar(l, gz, dst, stripComponents)
+}
+
+// extractTar extracts a tar archive into the 'dst' folder
+// 'stripComponents' leading path elements are removed from the entry names (same as 'tar --strip-components')
+// Only directories and regular files are extracted, other entries (e.g. symlinks and devices) are skipped
+// The entries with absolute paths, '..' or paths through symlinks outside of the 'dst' folder are rejected
+func extractTar(l *zap.SugaredLogger, r io.Reader, dst fs.FileSystem, stripComponents int) error {
+	tr := tar.NewReader(r)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		entryName := strings.TrimPrefix(hdr.Name, "./")
+		if stripComponents > 0 {
+			parts := strings.SplitN(entryName, "/", stripComponents+1)
+			if len(parts) <= stripComponents {
+				continue
+			}
+			entryName = parts[stripComponents]
+		}
+
+		if _, err = dst.SecurePath(entryName); err != nil {
+			return fmt.Errorf("invalid archive entry '%s': %w", hdr.Name, err)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err = dst.MkdirAll(entryName, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err = dst.WriteFileFromReader(entryName, tr, hdr.FileInfo().Mode().Perm()); err != nil {
+				return err
+			}
+		default:
+			l.Warnw("Skipping unsupported archive entry", "entry", hdr.Name, "type", string(hdr.Typeflag))
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package vender_test
 
 import (
+	"context"
+	"os"
 	"path"
 	"testing"
 
@@ -8,9 +10,9 @@ import (
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 
+	"github.com/home-sol/homectl/internal/testutil"
 	"github.com/home-sol/homectl/pkg/config"
 	"github.com/home-sol/homectl/pkg/fs"
-	"github.com/home-sol/homectl/pkg/logger"
 	"github.com/home-sol/homectl/pkg/vender"
 )
 
@@ -18,11 +20,17 @@ const (
 	workingDir = "../../examples/complete"
 )
 
+// newTestVender returns the vender for the repo file system with the 'vendor' config, without logging
+func newTestVender(fss fs.FileSystem, vendorConfig config.Vendor) *vender.Vender {
+	return vender.New(context.Background(), fss, vendorConfig, zap.NewNop().Sugar())
+}
+
 func TestVenderComponentPullCommand(t *testing.T) {
 
-	logger.Logger = zap.NewNop().Sugar()
+	osFs, err := fs.FromDir("")
+	require.NoError(t, err)
 
-	err := config.InitConfigFromDir(workingDir)
+	cfg, err := config.Load(osFs, workingDir, nil, zap.NewNop().Sugar())
 	require.NoError(t, err)
 
 	componentType := "terraform"
@@ -33,10 +41,10 @@ func TestVenderComponentPullCommand(t *testing.T) {
 	fss, err := fs.FromDir(workingDir)
 	require.NoError(t, err)
 
-	componentConfig, componentPath, err := config.ReadComponentFile(fss, component, componentType)
+	componentConfig, componentPath, err := cfg.ReadComponentFile(fss, component, componentType)
 	assert.Nil(t, err)
 
-	err = vender.ExecuteComponentVendorCommand(fss, componentConfig.Spec, component, componentPath, false, vendorCommand)
+	err = newTestVender(fss, cfg.Vendor).ExecuteComponentVendorCommand(componentConfig.Spec, component, componentPath, false, false, vendorCommand)
 	assert.Nil(t, err)
 
 	// Check if the correct files were pulled and written to the correct folder
@@ -69,4 +77,30 @@ func TestVenderComponentPullCommand(t *testing.T) {
 	assert.Nil(t, err)
 	err = fss.Remove(path.Join(componentPath, "versions.tf"))
 	assert.Nil(t, err)
+	err = os.RemoveAll(fss.GetRelativePath(path.Join(componentPath, ".vendor")))
+	assert.Nil(t, err)
+}
+
+func TestVenderComponentPullMemFileSystem(t *testing.T) {
+	server := testutil.NewArchiveServer(t, map[string]string{
+		"main.tf":      "# main\n",
+		"variables.tf": "# variables\n",
+	})
+
+	spec := config.VendorComponentSpec{
+		Source: config.VendorComponentSource{
+			Uri: server.URL + "/source.tar.gz",
+		},
+	}
+
+	fss := fs.NewMemFileSystem()
+	require.NoError(t, newTestVender(fss, config.Vendor{}).ExecuteComponentVendorCommand(spec, "infra/mem", "infra/mem", false, false, "pull"))
+
+	content, err := fss.ReadFile("infra/mem/main.tf")
+	require.NoError(t, err)
+	assert.Equal(t, "# main\n", string(content))
+	assert.True(t, fss.FileExists("infra/mem/variables.tf"))
+	assert.True(t, fss.FileExists("infra/mem/.vendor/base/main.tf"))
+
+	assert.NoDirExists(t, "infra")
 }
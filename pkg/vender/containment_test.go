@@ -0,0 +1,110 @@
+package vender_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/home-sol/homectl/internal/testutil"
+	"github.com/home-sol/homectl/pkg/config"
+	"github.com/home-sol/homectl/pkg/fs"
+)
+
+func TestVenderComponentPullHostileMixinFilename(t *testing.T) {
+	source := testutil.NewArchiveServer(t, map[string]string{"main.tf": "# main\n"})
+
+	requests := 0
+	mixin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write([]byte("# mixin\n"))
+	}))
+	t.Cleanup(mixin.Close)
+
+	root := t.TempDir()
+	fss, err := fs.FromDir(filepath.Join(root, "project"))
+	require.NoError(t, err)
+
+	for _, filename := range []string{"../../../.bashrc", "/tmp/.bashrc", "modules/../../.bashrc", "."} {
+		spec := config.VendorComponentSpec{
+			Source: config.VendorComponentSource{Uri: source.URL + "/source.tar.gz"},
+			Mixins: []config.VendorComponentMixins{{Uri: mixin.URL + "/context.tf", Filename: filename}},
+		}
+
+		err = newTestVender(fss, config.Vendor{}).ExecuteComponentVendorCommand(spec, "test", "infra/test", false, false, "pull")
+		require.Error(t, err, filename)
+		assert.Contains(t, err.Error(), "invalid 'filename'", filename)
+	}
+
+	// The filenames are rejected before any download
+	assert.Zero(t, requests)
+	assert.NoFileExists(t, filepath.Join(root, ".bashrc"))
+	assert.NoFileExists(t, fss.GetRelativePath("infra/test/main.tf"))
+}
+
+func TestVenderComponentPullHostileArchive(t *testing.T) {
+	root := t.TempDir()
+	fss, err := fs.FromDir(filepath.Join(root, "project"))
+	require.NoError(t, err)
+
+	for _, entry := range []string{"../../evil.tf", "/evil.tf", "modules/../../../evil.tf"} {
+		registry := newTestOciRegistry(t, "modules/label", "1.0.0", testutil.TarGz(t, map[string]string{
+			"main.tf": "# main\n",
+			entry:     "# evil\n",
+		}))
+
+		spec := config.VendorComponentSpec{
+			Source: config.VendorComponentSource{
+				Type: "oci",
+				Uri:  "oci://" + registry.host() + "/modules/label:1.0.0",
+			},
+		}
+
+		err = newTestVender(fss, config.Vendor{}).ExecuteComponentVendorCommand(spec, "label", "label", false, false, "pull")
+		require.Error(t, err, entry)
+		assert.Contains(t, err.Error(), "invalid archive entry", entry)
+	}
+
+	assert.NoFileExists(t, filepath.Join(root, "evil.tf"))
+	assert.NoFileExists(t, fss.GetRelativePath("label/main.tf"))
+}
+
+func TestVenderComponentPullHostileComponentFolder(t *testing.T) {
+	source := testutil.NewArchiveServer(t, map[string]string{"main.tf": "# main\n"})
+
+	fss, err := fs.FromDir(t.TempDir())
+	require.NoError(t, err)
+	outside := t.TempDir()
+
+	spec := config.VendorComponentSpec{
+		Source: config.VendorComponentSource{Uri: source.URL + "/source.tar.gz"},
+	}
+
+	// The '.vendor' folder of the component points outside of the project
+	require.NoError(t, os.MkdirAll(fss.GetRelativePath("test"), 0755))
+	require.NoError(t, os.Symlink(outside, fss.GetRelativePath("test/.vendor")))
+
+	err = newTestVender(fss, config.Vendor{}).ExecuteComponentVendorCommand(spec, "test", "test", false, false, "pull")
+	require.Error(t, err)
+	assert.ErrorIs(t, err, fs.ErrOutsideBaseDir)
+
+	entries, err := ioutil.ReadDir(outside)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+	assert.NoFileExists(t, fss.GetRelativePath("test/main.tf"))
+
+	// The component folder itself points outside of the project
+	require.NoError(t, os.Symlink(outside, fss.GetRelativePath("linked")))
+
+	err = newTestVender(fss, config.Vendor{}).ExecuteComponentVendorCommand(spec, "linked", "linked", false, false, "pull")
+	assert.ErrorIs(t, err, fs.ErrOutsideBaseDir)
+
+	entries, err = ioutil.ReadDir(outside)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
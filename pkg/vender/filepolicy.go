@@ -0,0 +1,288 @@
+package vender
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/otiai10/copy"
+
+	"github.com/home-sol/homectl/pkg/config"
+	"github.com/home-sol/homectl/pkg/fs"
+)
+
+const (
+	// SymlinkPreserve keeps the symlinks as symlinks (the default)
+	SymlinkPreserve = "preserve"
+	// SymlinkFollow replaces the symlinks with copies of the files and folders they point to
+	SymlinkFollow = "follow"
+	// SymlinkReject fails the pull if the source has any symlinks
+	SymlinkReject = "reject"
+
+	// fileMode is the mode of the vendored files
+	fileMode os.FileMode = 0644
+	// executableFileMode is the mode of the vendored executable files matching 'executable_paths'
+	executableFileMode os.FileMode = 0755
+	// dirMode is the mode of the vendored folders
+	dirMode os.FileMode = 0755
+
+	// maxSymlinkDepth limits the nested symlinks followed with 'symlinks: follow' (the same as ELOOP on Linux)
+	maxSymlinkDepth = 40
+)
+
+// filePolicy decides how the symlinks in the source are vendored ('symlinks'), and which files keep the exec bit ('executable_paths')
+type filePolicy struct {
+	symlinks   string
+	executable []pathRule
+}
+
+// newFilePolicy returns the file policy from 'symlinks' and 'executable_paths' of the source
+func newFilePolicy(source config.VendorComponentSource) (*filePolicy, error) {
+	p := &filePolicy{symlinks: source.Symlinks}
+
+	switch p.symlinks {
+	case "":
+		p.symlinks = SymlinkPreserve
+	case SymlinkPreserve, SymlinkFollow, SymlinkReject:
+	default:
+		return nil, fmt.Errorf("invalid 'symlinks' value '%s', supported values are '%s', '%s' and '%s'",
+			source.Symlinks, SymlinkPreserve, SymlinkFollow, SymlinkReject)
+	}
+
+	for _, pattern := range source.ExecutablePaths {
+		rule, err := newPathRule("executable_paths", pattern)
+		if err != nil {
+			return nil, err
+		}
+		p.executable = append(p.executable, rule)
+	}
+
+	return p, nil
+}
+
+// mode returns the normalized mode of the file: 0644, or 0755 if the file is executable and matches 'executable_paths'
+func (p *filePolicy) mode(file string, mode os.FileMode) os.FileMode {
+	if mode&0111 == 0 {
+		return fileMode
+	}
+	if rule := lastMatch(p.executable, file); rule != nil && !rule.negate {
+		return executableFileMode
+	}
+	return fileMode
+}
+
+// resolveSymlinks applies the 'symlinks' policy to the downloaded source in the OS folder. The symlinks must resolve inside the folder:
+// with 'follow', they are replaced with copies of their targets, with 'preserve' they are kept, and with 'reject' any symlink fails.
+// The '.git' folders are skipped
+func (p *filePolicy) resolveSymlinks(root string) error {
+	for depth := 0; ; depth++ {
+		links, err := findSymlinks(root)
+		if err != nil {
+			return err
+		}
+
+		if len(links) == 0 {
+			return nil
+		}
+
+		if p.symlinks == SymlinkReject {
+			return fmt.Errorf("the file '%s' is a symlink, symlinks are not allowed by 'symlinks: %s'", links[0], SymlinkReject)
+		}
+
+		if p.symlinks == SymlinkPreserve {
+			for _, link := range links {
+				if err = checkSymlink(root, link); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		// The followed folders can have symlinks too, they are followed in the next pass
+		if depth == maxSymlinkDepth {
+			return fmt.Errorf("the symlink '%s' can't be followed: too many levels of symlinks", links[0])
+		}
+
+		for _, link := range links {
+			if err = followSymlink(root, link); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// checkSymlinks checks the symlinks in the staging folder against the 'symlinks' policy. The symlinks are already
+// followed or rejected in the downloaded sources, but 'mappings' can move the preserved symlinks
+func (p *filePolicy) checkSymlinks(staging fs.FileSystem) error {
+	return staging.Walk(".", func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			return nil
+		}
+
+		link := filepath.ToSlash(name)
+		if p.symlinks == SymlinkReject {
+			return fmt.Errorf("the file '%s' is a symlink, symlinks are not allowed by 'symlinks: %s'", link, SymlinkReject)
+		}
+
+		target, err := staging.Readlink(name)
+		if err != nil {
+			return err
+		}
+
+		if filepath.IsAbs(target) || path.IsAbs(filepath.ToSlash(target)) {
+			return symlinkOutsideError(link, filepath.ToSlash(target))
+		}
+		if _, err = staging.SecurePath(filepath.Join(filepath.Dir(name), target)); err != nil {
+			return symlinkOutsideError(link, filepath.ToSlash(target))
+		}
+
+		return nil
+	})
+}
+
+// normalizeModes sets the modes of the files in the staging folder using 'executable_paths', and the modes of the folders to 0755
+func (p *filePolicy) normalizeModes(staging fs.FileSystem) error {
+	return staging.Walk(".", func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		var mode os.FileMode
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			return nil
+		case info.IsDir():
+			mode = dirMode
+		case info.Mode().IsRegular():
+			mode = p.mode(filepath.ToSlash(name), info.Mode())
+		default:
+			return fmt.Errorf("the file '%s' is not a regular file", filepath.ToSlash(name))
+		}
+
+		if info.Mode().Perm() == mode {
+			return nil
+		}
+		return staging.Chmod(name, mode)
+	})
+}
+
+// findSymlinks returns the symlinks in the folder (relative to the folder, slash-separated)
+func findSymlinks(root string) ([]string, error) {
+	var links []string
+
+	err := filepath.Walk(root, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			rel, err := filepath.Rel(root, name)
+			if err != nil {
+				return err
+			}
+			links = append(links, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+
+	return links, err
+}
+
+// checkSymlink checks that the symlink (relative to the root folder) is relative and resolves inside the root folder
+func checkSymlink(root string, link string) error {
+	target, err := os.Readlink(filepath.Join(root, filepath.FromSlash(link)))
+	if err != nil {
+		return err
+	}
+
+	target = filepath.ToSlash(target)
+	if path.IsAbs(target) || filepath.IsAbs(target) {
+		return symlinkOutsideError(link, target)
+	}
+
+	resolved := path.Join(path.Dir(link), target)
+	if resolved == ".." || strings.HasPrefix(resolved, "../") {
+		return symlinkOutsideError(link, target)
+	}
+
+	// The symlinks in the target path (e.g. 'dir/..' where 'dir' is a symlink) are resolved too,
+	// the dangling symlinks are checked only lexically
+	real, err := filepath.EvalSymlinks(filepath.Join(root, filepath.FromSlash(link)))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if !isInside(root, real) {
+		return symlinkOutsideError(link, target)
+	}
+
+	return nil
+}
+
+// followSymlink replaces the symlink (relative to the root folder) with a copy of the file or folder it points to
+func followSymlink(root string, link string) error {
+	name := filepath.Join(root, filepath.FromSlash(link))
+
+	target, err := os.Readlink(name)
+	if err != nil {
+		return err
+	}
+
+	real, err := filepath.EvalSymlinks(name)
+	if err != nil {
+		return fmt.Errorf("the symlink '%s' can't be followed: %w", link, err)
+	}
+
+	if !isInside(root, real) {
+		return symlinkOutsideError(link, filepath.ToSlash(target))
+	}
+
+	// A symlink to one of its parent folders would be copied into itself
+	if parent, err := filepath.EvalSymlinks(filepath.Dir(name)); err == nil && isInside(real, parent) {
+		return fmt.Errorf("the symlink '%s' can't be followed: it points to its parent folder '%s'", link, filepath.ToSlash(target))
+	}
+
+	if err = os.Remove(name); err != nil {
+		return err
+	}
+
+	// The symlinks in the copied folder are kept, so they are checked relative to their new location in the next pass
+	return copy.Copy(real, name, copy.Options{
+		OnSymlink: func(string) copy.SymlinkAction {
+			return copy.Shallow
+		},
+		PreserveTimes: false,
+		PreserveOwner: false,
+	})
+}
+
+// isInside checks if the path is the root folder or is inside it, after resolving the symlinks in the root folder path
+func isInside(root string, name string) bool {
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return false
+	}
+
+	rel, err := filepath.Rel(realRoot, name)
+	if err != nil {
+		return false
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+func symlinkOutsideError(link string, target string) error {
+	return fmt.Errorf("the symlink '%s' points to '%s' outside of the component, which is not allowed", link, target)
+}
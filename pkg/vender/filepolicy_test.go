@@ -0,0 +1,142 @@
+package vender_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/home-sol/homectl/pkg/config"
+	"github.com/home-sol/homectl/pkg/fs"
+	"github.com/home-sol/homectl/pkg/vender"
+)
+
+// newTestSymlinkRepository returns the git repository with the executable files and the symlinks
+func newTestSymlinkRepository(t *testing.T) string {
+	repo := newTestGitRepository(t, "0.0.1", map[string]string{
+		"modules/a/main.tf":        "# main\n",
+		"modules/a/scripts/run.sh": "#!/bin/sh\n",
+		"modules/b/main.tf":        "# b\n",
+	})
+
+	require.NoError(t, os.Chmod(filepath.Join(repo, "modules/a/main.tf"), 0755))
+	require.NoError(t, os.Chmod(filepath.Join(repo, "modules/a/scripts/run.sh"), 0755))
+	require.NoError(t, os.Symlink("main.tf", filepath.Join(repo, "modules/a/link.tf")))
+	require.NoError(t, os.Symlink("scripts", filepath.Join(repo, "modules/a/lib")))
+	require.NoError(t, os.Symlink("../a/main.tf", filepath.Join(repo, "modules/b/escape.tf")))
+
+	for _, args := range [][]string{{"add", "-A"}, {"commit", "--quiet", "-m", "symlinks"}, {"tag", "0.1.0"}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repo
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+		)
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+
+	return repo
+}
+
+func TestVenderComponentPullSymlinks(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	repo := newTestSymlinkRepository(t)
+
+	vendorConfig := config.Vendor{}
+	vendorConfig.Git.CacheDir = t.TempDir()
+
+	pull := func(fss fs.FileSystem, component string, symlinks string) error {
+		spec := config.VendorComponentSpec{
+			Source: config.VendorComponentSource{
+				Uri:             "git::file://" + filepath.ToSlash(repo) + "//modules/" + component + "?ref={{.Version}}",
+				Version:         "0.1.0",
+				Symlinks:        symlinks,
+				ExecutablePaths: []string{"*.sh"},
+			},
+		}
+		return newTestVender(fss, vendorConfig).ExecuteComponentVendorCommand(spec, component, component, false, false, "pull")
+	}
+
+	mode := func(fss fs.FileSystem, file string) os.FileMode {
+		info, err := os.Lstat(fss.GetRelativePath(file))
+		require.NoError(t, err)
+		return info.Mode()
+	}
+
+	t.Run("preserve", func(t *testing.T) {
+		fss, err := fs.FromDir(t.TempDir())
+		require.NoError(t, err)
+
+		require.NoError(t, pull(fss, "a", ""))
+
+		target, err := os.Readlink(fss.GetRelativePath("a/link.tf"))
+		require.NoError(t, err)
+		assert.Equal(t, "main.tf", target)
+
+		target, err = os.Readlink(fss.GetRelativePath("a/lib"))
+		require.NoError(t, err)
+		assert.Equal(t, "scripts", target)
+
+		// The exec bit is only kept for the files matching 'executable_paths'
+		assert.Equal(t, os.FileMode(0644), mode(fss, "a/main.tf").Perm())
+		assert.Equal(t, os.FileMode(0755), mode(fss, "a/scripts/run.sh").Perm())
+
+		// The unchanged symlinks are kept by the next pull
+		require.NoError(t, pull(fss, "a", vender.SymlinkPreserve))
+		assert.NotZero(t, mode(fss, "a/link.tf")&os.ModeSymlink)
+	})
+
+	t.Run("follow", func(t *testing.T) {
+		fss, err := fs.FromDir(t.TempDir())
+		require.NoError(t, err)
+
+		require.NoError(t, pull(fss, "a", vender.SymlinkFollow))
+
+		assert.True(t, mode(fss, "a/link.tf").IsRegular())
+		content, err := ioutil.ReadFile(fss.GetRelativePath("a/link.tf"))
+		require.NoError(t, err)
+		assert.Equal(t, "# main\n", string(content))
+
+		assert.True(t, mode(fss, "a/lib").IsDir())
+		assert.Equal(t, os.FileMode(0755), mode(fss, "a/lib/run.sh").Perm())
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		fss, err := fs.FromDir(t.TempDir())
+		require.NoError(t, err)
+
+		err = pull(fss, "a", vender.SymlinkReject)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "symlinks are not allowed")
+		assert.NoFileExists(t, fss.GetRelativePath("a/main.tf"))
+	})
+
+	t.Run("outside", func(t *testing.T) {
+		fss, err := fs.FromDir(t.TempDir())
+		require.NoError(t, err)
+
+		err = pull(fss, "b", vender.SymlinkPreserve)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "outside of the component")
+
+		assert.Error(t, pull(fss, "b", vender.SymlinkFollow))
+		assert.NoFileExists(t, fss.GetRelativePath("b/main.tf"))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		fss, err := fs.FromDir(t.TempDir())
+		require.NoError(t, err)
+
+		err = pull(fss, "a", "copy")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid 'symlinks' value 'copy'")
+	})
+}
@@ -0,0 +1,313 @@
+package vender
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/go-getter"
+	"github.com/otiai10/copy"
+	"go.uber.org/zap"
+
+	"github.com/home-sol/homectl/pkg/fs"
+)
+
+var (
+	gitCommitHashPattern = regexp.MustCompile(`^[0-9a-f]{40}([0-9a-f]{24})?$`)
+	// Abbreviated commit hashes (e.g. 'd63d7b7'), they can't be fetched by themselves
+	gitShortCommitHashPattern = regexp.MustCompile(`^[0-9a-f]{4,39}$`)
+	gitRefInvalidChars        = regexp.MustCompile(`[^A-Za-z0-9._/-]+`)
+)
+
+// downloadGitSource downloads the 'git::' source into the 'dst' folder.
+// The repository objects are stored in a bare repository (one per remote) in the cache folder, and only the requested
+// ref is fetched (shallow). Tags and commits that are already in the cache are not fetched again.
+// The '//subdir' of the source is checked out using sparse checkout.
+// Returns 'false' if the source uses go-getter options that are not supported (e.g. 'sshkey'), so go-getter is used instead
+func (v *Vender) downloadGitSource(ctx context.Context, src string, dst string) (bool, error) {
+	remote, subDir := getter.SourceDirSubdir(strings.TrimPrefix(src, "git::"))
+
+	u, err := url.Parse(remote)
+	if err != nil {
+		return false, nil
+	}
+
+	query := u.Query()
+	ref := query.Get("ref")
+	query.Del("ref")
+	if len(query) > 0 {
+		return false, nil
+	}
+	u.RawQuery = ""
+	remote = u.String()
+
+	l := v.logger.With("remote", remote, "ref", ref, "subdir", subDir)
+
+	env, cleanup, err := v.gitEnv()
+	if err != nil {
+		return true, err
+	}
+	defer cleanup()
+
+	repo, unlock, err := v.gitCacheRepository(ctx, l, env, remote)
+	if err != nil {
+		return true, err
+	}
+	defer unlock()
+
+	commit, err := v.gitFetchRef(ctx, env, repo, remote, ref)
+	if err != nil {
+		return true, err
+	}
+
+	l.Debugw("Checking out the git source", "commit", commit, "cache", repo)
+
+	workDir, err := ioutil.TempDir("", "homectl-git-")
+	if err != nil {
+		return true, err
+	}
+	defer func() {
+		if err := os.RemoveAll(workDir); err != nil {
+			l.Error(err)
+		}
+	}()
+
+	if err = v.gitCheckout(ctx, env, repo, workDir, commit, subDir); err != nil {
+		return true, err
+	}
+
+	copyOptions := copy.Options{
+		Skip: func(src string) (bool, error) {
+			return strings.HasSuffix(src, ".git"), nil
+		},
+		PreserveTimes: false,
+		PreserveOwner: false,
+	}
+
+	checkoutDir := filepath.Join(workDir, filepath.FromSlash(subDir))
+	if _, err = os.Stat(checkoutDir); err != nil {
+		return true, fmt.Errorf("subdir '%s' not found in '%s' at '%s'", subDir, remote, ref)
+	}
+
+	return true, copy.Copy(checkoutDir, dst, copyOptions)
+}
+
+// gitCacheRepository returns the bare repository for the remote in the cache folder, creating it if necessary.
+// The repository is locked until the returned function is called, so the pulls of other components from the same remote
+// don't fetch into it at the same time
+func (v *Vender) gitCacheRepository(ctx context.Context, l *zap.SugaredLogger, env []string, remote string) (string, func(), error) {
+	cacheDir := v.config.Git.CacheDir
+	if cacheDir == "" {
+		userCacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return "", nil, err
+		}
+		cacheDir = filepath.Join(userCacheDir, "homectl", "git")
+	}
+
+	sum := sha256.Sum256([]byte(remote))
+	name := fmt.Sprintf("%s-%s.git", strings.TrimSuffix(path.Base(remote), ".git"), hex.EncodeToString(sum[:])[:12])
+	repo := filepath.Join(cacheDir, name)
+
+	cacheFs, err := fs.FromDir(cacheDir)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// The lock file is next to the repository, 'TryLock' creates the cache folder
+	lock, err := v.waitForLock(l, cacheFs, name+".lock", true, fmt.Sprintf("the git cache of '%s'", remote))
+	if err != nil {
+		return "", nil, err
+	}
+	unlock := func() {
+		releaseLock(l, lock)
+	}
+
+	if _, err = os.Stat(filepath.Join(repo, "HEAD")); err == nil {
+		return repo, unlock, nil
+	}
+
+	if _, err = v.runGit(ctx, env, "", "init", "--bare", "--quiet", repo); err != nil {
+		unlock()
+		return "", nil, err
+	}
+
+	return repo, unlock, nil
+}
+
+// gitFetchRef returns the commit of the ref, fetching it from the remote if it's not in the cache yet.
+// Tags and commits are immutable and are used from the cache. Branches are fetched every time.
+// Abbreviated commit hashes that are not branch or tag names are found in the full history of the remote
+func (v *Vender) gitFetchRef(ctx context.Context, env []string, repo string, remote string, ref string) (string, error) {
+	gitDir := "--git-dir=" + repo
+
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	if gitCommitHashPattern.MatchString(ref) {
+		if _, err := v.runGit(ctx, env, "", gitDir, "cat-file", "-e", ref+"^{commit}"); err == nil {
+			return ref, nil
+		}
+	} else {
+		if gitShortCommitHashPattern.MatchString(ref) {
+			if commit, err := v.runGit(ctx, env, "", gitDir, "rev-parse", "--verify", "--quiet", ref+"^{commit}"); err == nil {
+				return commit, nil
+			}
+		}
+
+		if commit, err := v.runGit(ctx, env, "", gitDir, "rev-parse", "--verify", "--quiet", "refs/tags/"+ref+"^{commit}"); err == nil {
+			return commit, nil
+		}
+
+		// Try to fetch the ref as a tag first
+		tag := "refs/tags/" + ref
+		if _, err := v.runGit(ctx, env, "", gitDir, "fetch", "--quiet", "--depth=1", "--no-tags", remote, "+"+tag+":"+tag); err == nil {
+			return v.runGit(ctx, env, "", gitDir, "rev-parse", "--verify", tag+"^{commit}")
+		}
+	}
+
+	// Branches and commits are fetched into the 'refs/homectl' namespace, so they are not garbage collected
+	localRef := "refs/homectl/" + strings.Trim(gitRefInvalidChars.ReplaceAllString(ref, "_"), "/")
+	if _, err := v.runGit(ctx, env, "", gitDir, "fetch", "--quiet", "--depth=1", "--no-tags", remote, "+"+ref+":"+localRef); err != nil {
+		if !gitShortCommitHashPattern.MatchString(ref) {
+			return "", err
+		}
+		return v.gitFetchShortCommit(ctx, env, repo, remote, ref)
+	}
+
+	return v.runGit(ctx, env, "", gitDir, "rev-parse", "--verify", localRef+"^{commit}")
+}
+
+// gitFetchShortCommit fetches the full history of the branches and tags of the remote, and returns the commit
+// of the abbreviated hash. The branches are fetched into the 'refs/homectl/heads' namespace
+func (v *Vender) gitFetchShortCommit(ctx context.Context, env []string, repo string, remote string, ref string) (string, error) {
+	gitDir := "--git-dir=" + repo
+
+	args := []string{gitDir, "fetch", "--quiet", "--no-tags"}
+	// The history of the shallow cache repository is cut at the previously fetched commits
+	if _, err := os.Stat(filepath.Join(repo, "shallow")); err == nil {
+		args = append(args, "--unshallow")
+	}
+	args = append(args, remote, "+refs/heads/*:refs/homectl/heads/*", "+refs/tags/*:refs/tags/*")
+
+	if _, err := v.runGit(ctx, env, "", args...); err != nil {
+		return "", err
+	}
+
+	commit, err := v.runGit(ctx, env, "", gitDir, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("commit '%s' not found in the branches and tags of '%s'", ref, remote)
+	}
+
+	return commit, nil
+}
+
+// gitCheckout checks out the commit into 'workDir' using the objects from the cache repository.
+// If 'subDir' is specified, only the files in the 'subDir' are checked out (sparse checkout)
+func (v *Vender) gitCheckout(ctx context.Context, env []string, repo string, workDir string, commit string, subDir string) error {
+	if _, err := v.runGit(ctx, env, "", "init", "--quiet", workDir); err != nil {
+		return err
+	}
+
+	gitDir := filepath.Join(workDir, ".git")
+
+	// Use the objects of the cache repository instead of copying them
+	err := ioutil.WriteFile(filepath.Join(gitDir, "objects", "info", "alternates"), []byte(filepath.Join(repo, "objects")+"\n"), 0644)
+	if err != nil {
+		return err
+	}
+
+	// The cache repository is shallow, the working repository has the same history boundaries
+	if shallow, err := ioutil.ReadFile(filepath.Join(repo, "shallow")); err == nil {
+		if err = ioutil.WriteFile(filepath.Join(gitDir, "shallow"), shallow, 0644); err != nil {
+			return err
+		}
+	}
+
+	if subDir != "" {
+		if _, err = v.runGit(ctx, env, workDir, "config", "core.sparseCheckout", "true"); err != nil {
+			return err
+		}
+
+		pattern := "/" + strings.Trim(path.Clean(subDir), "/") + "/\n"
+		if err = ioutil.WriteFile(filepath.Join(gitDir, "info", "sparse-checkout"), []byte(pattern), 0644); err != nil {
+			return err
+		}
+	}
+
+	_, err = v.runGit(ctx, env, workDir, "checkout", "--quiet", "--detach", commit)
+	return err
+}
+
+// runGit runs the git command with the ENV vars from 'gitEnv' and returns its trimmed output
+func (v *Vender) runGit(ctx context.Context, env []string, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(append(os.Environ(), "GIT_TERMINAL_PROMPT=0"), env...)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("'git %s' failed: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// gitGetter downloads the git sources go-getter gets by itself (e.g. the mixins and the 'X-Terraform-Get' sources) using
+// the git cache and the 'vendor.http' settings (see 'downloadGitSource'). The sources with the options the cache
+// doesn't support (e.g. 'sshkey') are downloaded by the go-getter git getter, without the 'vendor.http' settings
+type gitGetter struct {
+	getter.Getter
+	v   *Vender
+	ctx context.Context
+}
+
+func (g *gitGetter) Get(dst string, u *url.URL) error {
+	if ok, err := g.v.downloadGitSource(g.ctx, "git::"+u.String(), dst); ok {
+		return err
+	}
+
+	if hasHttpSettings(g.v.config.Http) {
+		// The query is not logged, it can have the private key ('sshkey')
+		g.v.logger.Warnw("The git source is downloaded without the 'vendor.http' settings, its options are not supported by the git cache",
+			"remote", u.Host+u.Path)
+	}
+	return g.Getter.Get(dst, u)
+}
+
+// GetFile downloads the repository and copies the file from it. Same as in go-getter, the file is the last element of the path
+func (g *gitGetter) GetFile(dst string, u *url.URL) error {
+	tempDir, err := ioutil.TempDir("", "homectl-git-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			g.v.logger.Error(err)
+		}
+	}()
+
+	repo := *u
+	repo.Path = path.Dir(u.Path)
+
+	repoDir := filepath.Join(tempDir, "repo")
+	if err = g.Get(repoDir, &repo); err != nil {
+		return err
+	}
+
+	return copy.Copy(filepath.Join(repoDir, path.Base(u.Path)), dst)
+}
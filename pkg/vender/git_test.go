@@ -0,0 +1,157 @@
+package vender_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/home-sol/homectl/pkg/config"
+	"github.com/home-sol/homectl/pkg/fs"
+)
+
+// newTestGitRepository creates a repository with the files committed and tagged with 'tag'
+func newTestGitRepository(t *testing.T, tag string, files map[string]string) string {
+	repo := t.TempDir()
+
+	git := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repo
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+		)
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+
+	git("init", "--quiet")
+	for name, content := range files {
+		require.NoError(t, os.MkdirAll(filepath.Join(repo, filepath.Dir(name)), 0755))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(repo, name), []byte(content), 0644))
+	}
+	git("add", "-A")
+	git("commit", "--quiet", "-m", "initial")
+	git("tag", tag)
+
+	return repo
+}
+
+func TestVenderComponentPullGitSource(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	repo := newTestGitRepository(t, "0.1.0", map[string]string{
+		"modules/vpc/main.tf":    "# vpc\n",
+		"modules/vpc/README.md":  "# vpc\n",
+		"modules/bucket/main.tf": "# bucket\n",
+		"README.md":              "# components\n",
+	})
+
+	vendorConfig := config.Vendor{}
+	cacheDir := t.TempDir()
+	vendorConfig.Git.CacheDir = cacheDir
+
+	fss, err := fs.FromDir(t.TempDir())
+	require.NoError(t, err)
+
+	pull := func(component string) error {
+		spec := config.VendorComponentSpec{
+			Source: config.VendorComponentSource{
+				Uri:     "git::file://" + filepath.ToSlash(repo) + "//modules/" + component + "?ref={{.Version}}",
+				Version: "0.1.0",
+			},
+			// The mixins from git are downloaded using the same cache
+			Mixins: []config.VendorComponentMixins{
+				{Uri: "git::file://" + filepath.ToSlash(repo) + "/README.md?ref={{.Version}}", Version: "0.1.0", Filename: "COMPONENTS.md"},
+			},
+		}
+		return newTestVender(fss, vendorConfig).ExecuteComponentVendorCommand(spec, component, component, false, false, "pull")
+	}
+
+	require.NoError(t, pull("vpc"))
+	assert.FileExists(t, fss.GetRelativePath(path.Join("vpc", "main.tf")))
+	assert.FileExists(t, fss.GetRelativePath(path.Join("vpc", "README.md")))
+	assert.NoDirExists(t, fss.GetRelativePath(path.Join("vpc", "modules")))
+	assert.NoDirExists(t, fss.GetRelativePath(path.Join("vpc", ".git")))
+	assert.FileExists(t, fss.GetRelativePath(path.Join("vpc", "COMPONENTS.md")))
+
+	// The second component from the same repository and tag is checked out from the cache without fetching
+	require.NoError(t, os.RemoveAll(repo))
+	require.NoError(t, pull("bucket"))
+	assert.FileExists(t, fss.GetRelativePath(path.Join("bucket", "main.tf")))
+
+	// One bare repository for the remote, with its lock file
+	repos, err := filepath.Glob(filepath.Join(cacheDir, "*.git"))
+	require.NoError(t, err)
+	require.Len(t, repos, 1)
+	assert.FileExists(t, repos[0]+".lock")
+
+	// Another pull from the same remote holds the cache repository
+	cacheFs, err := fs.FromDir(cacheDir)
+	require.NoError(t, err)
+	lock, err := cacheFs.TryLock(filepath.Base(repos[0])+".lock", true)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, lock.Unlock()) }()
+
+	vendorConfig.Lock.Timeout = 200 * time.Millisecond
+	err = pull("vpc")
+	assert.ErrorIs(t, err, fs.ErrLocked)
+	assert.Contains(t, err.Error(), "waiting for the lock of the git cache of 'file://")
+}
+
+func TestVenderComponentPullGitCommit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	repo := newTestGitRepository(t, "0.1.0", map[string]string{"main.tf": "# first\n"})
+
+	git := func(args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repo
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+		)
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+		return strings.TrimSpace(string(out))
+	}
+
+	first := git("rev-parse", "HEAD")
+	require.NoError(t, ioutil.WriteFile(filepath.Join(repo, "main.tf"), []byte("# second\n"), 0644))
+	git("commit", "--quiet", "-am", "second")
+
+	vendorConfig := config.Vendor{}
+	vendorConfig.Git.CacheDir = t.TempDir()
+
+	pull := func(ref string) string {
+		fss, err := fs.FromDir(t.TempDir())
+		require.NoError(t, err)
+
+		spec := config.VendorComponentSpec{
+			Source: config.VendorComponentSource{
+				Uri: "git::file://" + filepath.ToSlash(repo) + "?ref=" + ref,
+			},
+		}
+		require.NoError(t, newTestVender(fss, vendorConfig).ExecuteComponentVendorCommand(spec, "test", "test", false, false, "pull"))
+
+		content, err := fss.ReadFile("test/main.tf")
+		require.NoError(t, err)
+		return string(content)
+	}
+
+	// The branch is fetched shallow first, so the short hash of the older commit needs the full history
+	assert.Equal(t, "# second\n", pull(git("rev-parse", "--abbrev-ref", "HEAD")))
+	assert.Equal(t, "# first\n", pull(first[:7]))
+	assert.Equal(t, "# first\n", pull(first))
+}
@@ -0,0 +1,200 @@
+package vender
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"gopkg.in/yaml.v2"
+
+	"github.com/home-sol/homectl/pkg/config"
+	"github.com/home-sol/homectl/pkg/fs"
+)
+
+// helmIndex is the 'index.yaml' file of a Helm chart repository
+// https://helm.sh/docs/topics/chart_repository/#the-index-file
+type helmIndex struct {
+	ApiVersion string                      `yaml:"apiVersion"`
+	Entries    map[string][]helmChartEntry `yaml:"entries"`
+}
+
+type helmChartEntry struct {
+	Name    string   `yaml:"name"`
+	Version string   `yaml:"version"`
+	Digest  string   `yaml:"digest"`
+	Urls    []string `yaml:"urls"`
+}
+
+// downloadHelmSource downloads the chart 'source.chart' from the Helm chart repository 'uri', verifies its digest
+// and unpacks it into the 'dst' folder
+// 'source.version' can be an exact version or a semver range (e.g. '^1.2.0', '>= 1.2, < 2.0'). If 'version' is not
+// specified, the latest stable version of the chart is used
+func (v *Vender) downloadHelmSource(ctx context.Context, source config.VendorComponentSource, uri string, dst string) error {
+	if source.Chart == "" {
+		return errors.New("'chart' must be specified in 'source.chart' in the 'component.yaml' file for sources with 'type: helm'")
+	}
+
+	if err := v.checkSourcePolicy(SourceTypeHelm, uri); err != nil {
+		return err
+	}
+
+	indexURL, err := url.Parse(strings.TrimSuffix(uri, "/") + "/index.yaml")
+	if err != nil {
+		return err
+	}
+
+	index, err := v.fetchHelmIndex(ctx, indexURL)
+	if err != nil {
+		return err
+	}
+
+	entry, err := resolveHelmChartVersion(index, source.Chart, source.Version)
+	if err != nil {
+		return fmt.Errorf("error resolving chart '%s' in the Helm repository '%s': %w", source.Chart, uri, err)
+	}
+
+	if len(entry.Urls) == 0 {
+		return fmt.Errorf("chart '%s' version '%s' in the Helm repository '%s' does not have any download URLs", source.Chart, entry.Version, uri)
+	}
+
+	// Chart URLs can be relative to the repository index
+	chartURL, err := indexURL.Parse(entry.Urls[0])
+	if err != nil {
+		return err
+	}
+
+	v.logger.Infow("Resolved Helm chart", "chart", source.Chart, "version", entry.Version, "url", chartURL.String())
+
+	// The chart URLs in the index can point to other hosts
+	if err = v.checkSourcePolicy(SourceTypeHelm, chartURL.String()); err != nil {
+		return err
+	}
+
+	content, err := v.httpGet(ctx, chartURL.String())
+	if err != nil {
+		return err
+	}
+
+	if entry.Digest != "" {
+		sum := sha256.Sum256(content)
+		if actual := hex.EncodeToString(sum[:]); actual != strings.TrimPrefix(entry.Digest, "sha256:") {
+			return fmt.Errorf("digest mismatch for chart '%s' version '%s': expected '%s', got '%s'", source.Chart, entry.Version, entry.Digest, actual)
+		}
+	}
+
+	if err = os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+
+	dstFs, err := fs.FromDir(dst)
+	if err != nil {
+		return err
+	}
+
+	// Chart archives have the chart name as the top-level folder, the files are unpacked without it
+	return extractTarGz(v.logger, bytes.NewReader(content), dstFs, 1)
+}
+
+func (v *Vender) fetchHelmIndex(ctx context.Context, indexURL *url.URL) (helmIndex, error) {
+	var index helmIndex
+
+	content, err := v.httpGet(ctx, indexURL.String())
+	if err != nil {
+		return index, err
+	}
+
+	if err = yaml.Unmarshal(content, &index); err != nil {
+		return index, fmt.Errorf("error parsing the Helm repository index '%s': %w", indexURL, err)
+	}
+
+	return index, nil
+}
+
+// resolveHelmChartVersion finds the highest chart version that satisfies the version constraint
+func resolveHelmChartVersion(index helmIndex, chart string, version string) (helmChartEntry, error) {
+	var resolved helmChartEntry
+	var resolvedVersion *semver.Version
+
+	entries, ok := index.Entries[chart]
+	if !ok || len(entries) == 0 {
+		return resolved, fmt.Errorf("chart '%s' not found", chart)
+	}
+
+	var constraint *semver.Constraints
+	if version != "" {
+		c, err := semver.NewConstraint(version)
+		if err != nil {
+			return resolved, fmt.Errorf("invalid version constraint '%s': %w", version, err)
+		}
+		constraint = c
+	}
+
+	for _, entry := range entries {
+		v, err := semver.NewVersion(entry.Version)
+		if err != nil {
+			continue
+		}
+
+		// Exact versions are matched as-is, so pre-release versions can be pinned
+		if version != "" && entry.Version == version {
+			return entry, nil
+		}
+
+		if constraint != nil && !constraint.Check(v) {
+			continue
+		}
+		if constraint == nil && v.Prerelease() != "" {
+			continue
+		}
+
+		if resolvedVersion == nil || v.GreaterThan(resolvedVersion) {
+			resolved = entry
+			resolvedVersion = v
+		}
+	}
+
+	if resolvedVersion == nil {
+		if version == "" {
+			return resolved, errors.New("no stable version found")
+		}
+		return resolved, fmt.Errorf("no version matches '%s'", version)
+	}
+
+	return resolved, nil
+}
+
+// httpGet downloads the content from the URL
+func (v *Vender) httpGet(ctx context.Context, uri string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient, err := v.newHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		return nil, fmt.Errorf("error downloading '%s': %s", uri, resp.Status)
+	}
+
+	return ioutil.ReadAll(v.limitReader(uri, resp.Body))
+}
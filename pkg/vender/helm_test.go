@@ -0,0 +1,140 @@
+package vender_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/home-sol/homectl/internal/testutil"
+	"github.com/home-sol/homectl/pkg/config"
+	"github.com/home-sol/homectl/pkg/fs"
+)
+
+// newTestHelmRepository serves an 'index.yaml' with the chart versions and the chart archives
+func newTestHelmRepository(t *testing.T, chart string, charts map[string][]byte, digests map[string]string) *httptest.Server {
+	mux := http.NewServeMux()
+
+	index := fmt.Sprintf("apiVersion: v1\nentries:\n  %s:\n", chart)
+	for version, content := range charts {
+		digest, ok := digests[version]
+		if !ok {
+			sum := sha256.Sum256(content)
+			digest = hex.EncodeToString(sum[:])
+		}
+		archive := fmt.Sprintf("%s-%s.tgz", chart, version)
+		index += fmt.Sprintf("    - name: %s\n      version: %s\n      digest: %s\n      urls:\n        - charts/%s\n", chart, version, digest, archive)
+
+		content := content
+		mux.HandleFunc("/charts/"+archive, func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write(content)
+		})
+	}
+
+	mux.HandleFunc("/index.yaml", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(index))
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func helmChart(t *testing.T, chart string, version string) []byte {
+	return testutil.TarGz(t, map[string]string{
+		chart + "/Chart.yaml":                "name: " + chart + "\nversion: " + version + "\n",
+		chart + "/values.yaml":               "replicaCount: 1\n",
+		chart + "/templates/deployment.yaml": "kind: Deployment\n",
+		chart + "/README.md":                 "# " + chart + "\n",
+	})
+}
+
+func TestVenderComponentPullHelmSource(t *testing.T) {
+	repository := newTestHelmRepository(t, "nginx", map[string][]byte{
+		"1.0.0":       helmChart(t, "nginx", "1.0.0"),
+		"1.2.0":       helmChart(t, "nginx", "1.2.0"),
+		"1.3.0-rc.1":  helmChart(t, "nginx", "1.3.0-rc.1"),
+		"2.0.0":       helmChart(t, "nginx", "2.0.0"),
+		"not-semver!": helmChart(t, "nginx", "0.0.0"),
+	}, nil)
+
+	tests := []struct {
+		version  string
+		expected string
+	}{
+		{version: "", expected: "2.0.0"},
+		{version: "^1.0.0", expected: "1.2.0"},
+		{version: ">= 1.0, < 1.2", expected: "1.0.0"},
+		{version: "1.3.0-rc.1", expected: "1.3.0-rc.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			fss, err := fs.FromDir(t.TempDir())
+			require.NoError(t, err)
+
+			componentPath := "components/helmfile/nginx"
+			spec := config.VendorComponentSpec{
+				Source: config.VendorComponentSource{
+					Type:          "helm",
+					Uri:           repository.URL,
+					Chart:         "nginx",
+					Version:       tt.version,
+					IncludedPaths: []string{"*.yaml"},
+				},
+			}
+
+			err = newTestVender(fss, config.Vendor{}).ExecuteComponentVendorCommand(spec, "nginx", componentPath, false, false, "pull")
+			require.NoError(t, err)
+
+			chartFile, err := ioutil.ReadFile(fss.GetRelativePath(path.Join(componentPath, "Chart.yaml")))
+			require.NoError(t, err)
+			assert.Contains(t, string(chartFile), "version: "+tt.expected)
+
+			assert.FileExists(t, fss.GetRelativePath(path.Join(componentPath, "values.yaml")))
+			assert.FileExists(t, fss.GetRelativePath(path.Join(componentPath, "templates/deployment.yaml")))
+			assert.NoFileExists(t, fss.GetRelativePath(path.Join(componentPath, "README.md")))
+		})
+	}
+}
+
+func TestVenderComponentPullHelmSourceErrors(t *testing.T) {
+	repository := newTestHelmRepository(t, "nginx", map[string][]byte{
+		"1.0.0": helmChart(t, "nginx", "1.0.0"),
+	}, map[string]string{
+		"1.0.0": "0000000000000000000000000000000000000000000000000000000000000000",
+	})
+
+	fss, err := fs.FromDir(t.TempDir())
+	require.NoError(t, err)
+
+	spec := config.VendorComponentSpec{
+		Source: config.VendorComponentSource{
+			Type:    "helm",
+			Uri:     repository.URL,
+			Chart:   "nginx",
+			Version: "1.0.0",
+		},
+	}
+
+	err = newTestVender(fss, config.Vendor{}).ExecuteComponentVendorCommand(spec, "nginx", "nginx", false, false, "pull")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "digest mismatch")
+	assert.NoFileExists(t, fss.GetRelativePath("nginx/Chart.yaml"))
+
+	spec.Source.Version = "^2.0.0"
+	err = newTestVender(fss, config.Vendor{}).ExecuteComponentVendorCommand(spec, "nginx", "nginx", false, false, "pull")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no version matches")
+
+	spec.Source.Chart = ""
+	err = newTestVender(fss, config.Vendor{}).ExecuteComponentVendorCommand(spec, "nginx", "nginx", false, false, "pull")
+	assert.Error(t, err)
+}
@@ -0,0 +1,225 @@
+package vender
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+
+	"github.com/home-sol/homectl/pkg/config"
+)
+
+// systemCaBundles are the locations of the system CA bundle on the common Linux distributions and macOS
+var systemCaBundles = []string{
+	"/etc/ssl/certs/ca-certificates.crt",
+	"/etc/pki/tls/certs/ca-bundle.crt",
+	"/etc/ssl/ca-bundle.pem",
+	"/etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem",
+	"/etc/pki/tls/cacert.pem",
+	"/etc/ssl/cert.pem",
+}
+
+// newHTTPClient returns the HTTP client for the downloads, using the proxy, CA bundles and client certificate from 'vendor.http'
+func (v *Vender) newHTTPClient() (*http.Client, error) {
+	transport, err := v.newHTTPTransport()
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{Transport: transport, CheckRedirect: v.checkRedirect}, nil
+}
+
+// newHTTPTransport returns the HTTP transport using the proxy, CA bundles and client certificate from 'vendor.http'.
+// Without the proxy settings, the 'HTTP_PROXY', 'HTTPS_PROXY' and 'NO_PROXY' ENV vars are used
+func (v *Vender) newHTTPTransport() (*http.Transport, error) {
+	httpConfig := v.config.Http
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if httpConfig.Proxy != "" || httpConfig.HttpsProxy != "" || len(httpConfig.NoProxy) > 0 {
+		proxyFunc := proxyConfig(httpConfig).ProxyFunc()
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			return proxyFunc(req.URL)
+		}
+	}
+
+	tlsConfig, err := newTLSConfig(httpConfig)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return transport, nil
+}
+
+// proxyConfig returns the proxy settings from 'vendor.http' on top of the proxy ENV vars.
+// 'proxy' is used for both 'http' and 'https' if 'https_proxy' is not specified
+func proxyConfig(httpConfig config.Http) *httpproxy.Config {
+	proxy := httpproxy.FromEnvironment()
+
+	if httpConfig.Proxy != "" {
+		proxy.HTTPProxy = httpConfig.Proxy
+		proxy.HTTPSProxy = httpConfig.Proxy
+	}
+	if httpConfig.HttpsProxy != "" {
+		proxy.HTTPSProxy = httpConfig.HttpsProxy
+	}
+	if len(httpConfig.NoProxy) > 0 {
+		proxy.NoProxy = strings.Join(httpConfig.NoProxy, ",")
+	}
+
+	return proxy
+}
+
+// hasHttpSettings checks if any of the 'vendor.http' settings is specified
+func hasHttpSettings(httpConfig config.Http) bool {
+	return httpConfig.Proxy != "" || httpConfig.HttpsProxy != "" || len(httpConfig.NoProxy) > 0 ||
+		len(httpConfig.CaBundles) > 0 || httpConfig.ClientCert != "" || httpConfig.ClientKey != ""
+}
+
+// newTLSConfig returns the TLS config trusting the system CAs and the 'ca_bundles', and presenting the client certificate.
+// Returns nil if neither is configured
+func newTLSConfig(httpConfig config.Http) (*tls.Config, error) {
+	if len(httpConfig.CaBundles) == 0 && httpConfig.ClientCert == "" && httpConfig.ClientKey == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if len(httpConfig.CaBundles) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+
+		for _, bundle := range httpConfig.CaBundles {
+			pem, err := ioutil.ReadFile(bundle)
+			if err != nil {
+				return nil, fmt.Errorf("error reading the CA bundle '%s' from 'vendor.http.ca_bundles': %w", bundle, err)
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in the CA bundle '%s' from 'vendor.http.ca_bundles'", bundle)
+			}
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	if httpConfig.ClientCert != "" || httpConfig.ClientKey != "" {
+		if httpConfig.ClientCert == "" || httpConfig.ClientKey == "" {
+			return nil, errors.New("both 'vendor.http.client_cert' and 'vendor.http.client_key' must be specified")
+		}
+
+		cert, err := tls.LoadX509KeyPair(httpConfig.ClientCert, httpConfig.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("error loading the client certificate from 'vendor.http.client_cert': %w", err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// gitEnv returns the ENV vars that pass the proxy, CA bundles and client certificate from 'vendor.http' to git,
+// and the function removing the files created for them
+func (v *Vender) gitEnv() ([]string, func(), error) {
+	httpConfig := v.config.Http
+
+	var env []string
+
+	if httpConfig.Proxy != "" || httpConfig.HttpsProxy != "" || len(httpConfig.NoProxy) > 0 {
+		proxy := proxyConfig(httpConfig)
+		// git (libcurl) only reads the lower case 'http_proxy'
+		env = append(env,
+			"http_proxy="+proxy.HTTPProxy,
+			"https_proxy="+proxy.HTTPSProxy, "HTTPS_PROXY="+proxy.HTTPSProxy,
+			"no_proxy="+proxy.NoProxy, "NO_PROXY="+proxy.NoProxy,
+		)
+	}
+
+	cleanup := func() {}
+
+	// 'GIT_SSL_CAINFO' replaces the system CA bundle, so the 'ca_bundles' are appended to it in a separate file
+	if len(httpConfig.CaBundles) > 0 {
+		caInfo, err := gitCaBundle(httpConfig.CaBundles)
+		if err != nil {
+			return nil, nil, err
+		}
+		env = append(env, "GIT_SSL_CAINFO="+caInfo)
+
+		cleanup = func() {
+			if err := os.Remove(caInfo); err != nil {
+				v.logger.Error(err)
+			}
+		}
+	}
+
+	if httpConfig.ClientCert != "" {
+		env = append(env, "GIT_SSL_CERT="+httpConfig.ClientCert)
+	}
+	if httpConfig.ClientKey != "" {
+		env = append(env, "GIT_SSL_KEY="+httpConfig.ClientKey)
+	}
+
+	return env, cleanup, nil
+}
+
+// gitCaBundle writes the system CA bundle and the 'ca_bundles' into a new temp file readable only by the current user,
+// and returns its path. The file is created for each pull, so it can't be replaced by another user of the temp folder
+func gitCaBundle(bundles []string) (string, error) {
+	var content bytes.Buffer
+
+	system := os.Getenv("SSL_CERT_FILE")
+	if system == "" {
+		for _, bundle := range systemCaBundles {
+			if _, err := os.Stat(bundle); err == nil {
+				system = bundle
+				break
+			}
+		}
+	}
+
+	for _, bundle := range append([]string{system}, bundles...) {
+		if bundle == "" {
+			continue
+		}
+
+		pem, err := ioutil.ReadFile(bundle)
+		if err != nil {
+			return "", fmt.Errorf("error reading the CA bundle '%s': %w", bundle, err)
+		}
+
+		content.Write(pem)
+		content.WriteString("\n")
+	}
+
+	// 'ioutil.TempFile' creates the file with the '0600' mode
+	f, err := ioutil.TempFile("", "homectl-ca-bundle-*.pem")
+	if err != nil {
+		return "", err
+	}
+
+	if _, err = f.Write(content.Bytes()); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return "", err
+	}
+
+	if err = f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return "", err
+	}
+
+	return f.Name(), nil
+}
@@ -0,0 +1,134 @@
+package vender_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/home-sol/homectl/internal/testutil"
+	"github.com/home-sol/homectl/pkg/config"
+	"github.com/home-sol/homectl/pkg/fs"
+)
+
+// writeClientCertificate writes a self-signed client certificate and its key, and returns the file names
+func writeClientCertificate(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "homectl-test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "client.crt")
+	keyFile := filepath.Join(dir, "client.key")
+	require.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644))
+	require.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+
+	return certFile, keyFile
+}
+
+func TestVenderComponentPullTLS(t *testing.T) {
+	archive := testutil.TarGz(t, map[string]string{"main.tf": "# main\n"})
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(archive)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	// The failed handshakes are expected
+	server.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	caBundle := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, ioutil.WriteFile(caBundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0644))
+
+	certFile, keyFile := writeClientCertificate(t)
+
+	vendorConfig := config.Vendor{}
+
+	spec := config.VendorComponentSpec{
+		Source: config.VendorComponentSource{
+			Uri: server.URL + "/source.tar.gz",
+		},
+	}
+
+	pull := func(httpConfig config.Http) error {
+		vendorConfig.Http = httpConfig
+
+		fss, err := fs.FromDir(t.TempDir())
+		require.NoError(t, err)
+
+		return newTestVender(fss, vendorConfig).ExecuteComponentVendorCommand(spec, "test", "test", false, false, "pull")
+	}
+
+	// The server certificate is not trusted
+	assert.Error(t, pull(config.Http{}))
+
+	// The server requires a client certificate
+	assert.Error(t, pull(config.Http{CaBundles: []string{caBundle}}))
+
+	assert.NoError(t, pull(config.Http{CaBundles: []string{caBundle}, ClientCert: certFile, ClientKey: keyFile}))
+
+	err := pull(config.Http{CaBundles: []string{certFile + ".missing"}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "'vendor.http.ca_bundles'")
+}
+
+func TestVenderComponentPullProxy(t *testing.T) {
+	archive := testutil.TarGz(t, map[string]string{"main.tf": "# main\n"})
+
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		_, _ = w.Write(archive)
+	}))
+	t.Cleanup(proxy.Close)
+
+	vendorConfig := config.Vendor{}
+
+	spec := config.VendorComponentSpec{
+		Source: config.VendorComponentSource{
+			Uri: "http://vendor.example.invalid/source.tar.gz",
+		},
+	}
+
+	fss, err := fs.FromDir(t.TempDir())
+	require.NoError(t, err)
+
+	vendorConfig.Http = config.Http{Proxy: proxy.URL}
+	require.NoError(t, newTestVender(fss, vendorConfig).ExecuteComponentVendorCommand(spec, "test", "test", false, false, "pull"))
+	assert.FileExists(t, fss.GetRelativePath("test/main.tf"))
+	assert.Contains(t, proxied, "http://vendor.example.invalid/source.tar.gz")
+
+	// The hosts from 'no_proxy' are accessed directly
+	proxied = nil
+	vendorConfig.Http = config.Http{Proxy: proxy.URL, NoProxy: []string{".example.invalid"}}
+	assert.Error(t, newTestVender(fss, vendorConfig).ExecuteComponentVendorCommand(spec, "test", "test", false, false, "pull"))
+	assert.Empty(t, proxied)
+}
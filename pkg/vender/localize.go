@@ -0,0 +1,307 @@
+package vender
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/go-getter"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/home-sol/homectl/pkg/config"
+	"github.com/home-sol/homectl/pkg/fs"
+)
+
+// vendoredModulesDir is the folder (relative to the component folder) into which the remote modules are downloaded
+const vendoredModulesDir = "modules/vendored"
+
+var localModuleDirInvalidChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// localizeModules downloads the remote sources of the 'module' blocks in the Terraform files in the component folder
+// into 'modules/vendored', and rewrites the 'source' attributes to the local paths.
+// The downloaded modules are localized recursively. Modules with the same 'source' and 'version' are downloaded once,
+// and so are the packages (e.g. git repositories) of the modules in their '//subdir'.
+// The symlinks in the downloaded modules are handled using the 'symlinks' policy of the component
+func (v *Vender) localizeModules(ctx context.Context, component fs.FileSystem, policy *filePolicy) error {
+	m := &moduleLocalizer{
+		v:         v,
+		ctx:       ctx,
+		fs:        component,
+		policy:    policy,
+		fetched:   map[string]string{},
+		packages:  map[string]string{},
+		localized: map[string]bool{},
+	}
+
+	return m.localizeDir(".")
+}
+
+type moduleLocalizer struct {
+	v   *Vender
+	ctx context.Context
+	// The component folder
+	fs     fs.FileSystem
+	policy *filePolicy
+	// Local folders of the downloaded modules by 'source' and 'version'
+	fetched map[string]string
+	// Local folders of the downloaded packages by the go-getter source without the '//subdir'
+	packages map[string]string
+	// The localized folders of the downloaded modules
+	localized map[string]bool
+}
+
+// localizeDir localizes the Terraform files in the folder (relative to the component folder) and its sub-folders
+func (m *moduleLocalizer) localizeDir(dir string) error {
+	vendoredDir := filepath.FromSlash(vendoredModulesDir)
+
+	return m.fs.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			// The downloaded modules are localized when they are downloaded
+			if p == vendoredDir || info.Name() == ".git" || info.Name() == ".terraform" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if filepath.Ext(p) != ".tf" {
+			return nil
+		}
+
+		return m.localizeFile(p)
+	})
+}
+
+// localizeFile rewrites the remote 'source' attributes of the 'module' blocks in the Terraform file (relative to the component folder)
+func (m *moduleLocalizer) localizeFile(file string) error {
+	content, err := m.fs.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	f, diags := hclwrite.ParseConfig(content, file, hcl.InitialPos)
+	if diags.HasErrors() {
+		return diags
+	}
+
+	changed := false
+
+	for _, block := range f.Body().Blocks() {
+		if block.Type() != "module" {
+			continue
+		}
+
+		body := block.Body()
+
+		source, ok := attributeStringValue(body.GetAttribute("source"))
+		if !ok {
+			continue
+		}
+
+		if isLocalModuleSource(source) {
+			// The other modules of the downloaded packages are localized when they are used
+			target := filepath.Join(filepath.Dir(file), filepath.FromSlash(source))
+			if isSubDir(target, filepath.FromSlash(vendoredModulesDir)) {
+				if err = m.localizeModuleDir(target); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		version, _ := attributeStringValue(body.GetAttribute("version"))
+
+		moduleDir, err := m.fetch(source, version)
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(filepath.Dir(file), moduleDir)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if !strings.HasPrefix(rel, "../") {
+			rel = "./" + rel
+		}
+
+		m.v.logger.Debugw("Localizing the module", "file", file, "module", block.Labels(), "source", source, "localSource", rel)
+
+		// Terraform does not allow 'version' for local modules
+		body.SetAttributeValue("source", cty.StringVal(rel))
+		body.RemoveAttribute("version")
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return m.fs.WriteFile(file, f.Bytes(), 0644)
+}
+
+// fetch downloads the package of the module into 'modules/vendored' (if it was not downloaded yet) and localizes the module.
+// The module is in the '//subdir' of its package, so the relative paths to the other modules of the package (e.g. '../x') work
+func (m *moduleLocalizer) fetch(source string, version string) (string, error) {
+	key := source + "@" + version
+	if moduleDir, ok := m.fetched[key]; ok {
+		return moduleDir, nil
+	}
+
+	// The registry modules are downloaded into the folders of the resolved versions, not of the version constraints
+	src := source
+	if isTerraformRegistryAddress(source) {
+		var err error
+		if src, version, err = m.v.resolveTerraformRegistrySource(m.ctx, config.VendorComponentSource{Version: version}, source); err != nil {
+			return "", err
+		}
+	}
+
+	root, subDir := getter.SourceDirSubdir(src)
+	name, _ := getter.SourceDirSubdir(source)
+
+	packageDir, err := m.fetchPackage(root, localModuleDir(name, version))
+	if err != nil {
+		return "", err
+	}
+
+	moduleDir := filepath.Join(packageDir, filepath.FromSlash(subDir))
+	if ok, err := m.fs.IsDirectory(moduleDir); err != nil || !ok {
+		return "", fmt.Errorf("subdir '%s' not found in the module '%s'", subDir, source)
+	}
+
+	m.fetched[key] = moduleDir
+
+	return moduleDir, m.localizeModuleDir(moduleDir)
+}
+
+// fetchPackage downloads the go-getter source into the folder in 'modules/vendored', if it was not downloaded yet
+func (m *moduleLocalizer) fetchPackage(src string, name string) (string, error) {
+	if packageDir, ok := m.packages[src]; ok {
+		return packageDir, nil
+	}
+
+	packageDir := filepath.FromSlash(path.Join(vendoredModulesDir, name))
+	if _, err := m.fs.SecurePath(packageDir); err != nil {
+		return "", err
+	}
+
+	// Different sources can have the same folder name (e.g. 'a/b?c' and 'a/b/c'), or the folder inside the folder of another
+	// source (e.g. 'example.com/repo' and 'example.com/repo?ref=1.0'), they would overwrite each other
+	for other, otherDir := range m.packages {
+		if packageDir == otherDir || isSubDir(packageDir, otherDir) || isSubDir(otherDir, packageDir) {
+			return "", fmt.Errorf("modules '%s' and '%s' can't be localized into the overlapping folders '%s' and '%s'",
+				src, other, filepath.ToSlash(packageDir), filepath.ToSlash(otherDir))
+		}
+	}
+
+	m.packages[src] = packageDir
+
+	m.v.logger.Infow("Downloading the module", "source", src, "path", packageDir)
+
+	tempDir, err := ioutil.TempDir("", "homectl-module-")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			m.v.logger.Error(err)
+		}
+	}()
+
+	downloadDir := filepath.Join(tempDir, "module")
+
+	if err = m.v.downloadGetterSource(m.ctx, src, downloadDir); err != nil {
+		return "", err
+	}
+
+	if err = m.policy.resolveSymlinks(downloadDir); err != nil {
+		return "", err
+	}
+
+	if err = m.fs.RemoveAll(packageDir); err != nil {
+		return "", err
+	}
+
+	return packageDir, stageDir(m.fs, downloadDir, packageDir)
+}
+
+// localizeModuleDir localizes the folder of the downloaded module once. The folder is registered before localizing it to break cycles
+func (m *moduleLocalizer) localizeModuleDir(dir string) error {
+	if m.localized[dir] {
+		return nil
+	}
+	m.localized[dir] = true
+
+	return m.localizeDir(dir)
+}
+
+// isSubDir checks if the folder is inside the parent folder
+func isSubDir(dir string, parent string) bool {
+	return strings.HasPrefix(dir, parent+string(filepath.Separator))
+}
+
+// attributeStringValue returns the value of the attribute if it is a string literal without interpolations
+func attributeStringValue(attr *hclwrite.Attribute) (string, bool) {
+	if attr == nil {
+		return "", false
+	}
+
+	tokens := attr.Expr().BuildTokens(nil)
+	if len(tokens) < 2 || tokens[0].Type != hclsyntax.TokenOQuote || tokens[len(tokens)-1].Type != hclsyntax.TokenCQuote {
+		return "", false
+	}
+
+	var value strings.Builder
+	for _, token := range tokens[1 : len(tokens)-1] {
+		if token.Type != hclsyntax.TokenQuotedLit {
+			return "", false
+		}
+		value.Write(token.Bytes)
+	}
+
+	return value.String(), true
+}
+
+// isLocalModuleSource checks if the module source is a local path
+// https://www.terraform.io/language/modules/sources#local-paths
+func isLocalModuleSource(source string) bool {
+	return strings.HasPrefix(source, "./") || strings.HasPrefix(source, "../") || source == "." || source == ".."
+}
+
+// localModuleDir returns the folder name for the module source, e.g. 'git::https://github.com/org/repo.git?ref=1.0'
+// is downloaded into 'github.com/org/repo.git/ref_1.0', and the registry module 'cloudposse/label/null' version '0.25.0'
+// into 'cloudposse/label/null/0.25.0'
+func localModuleDir(source string, version string) string {
+	if i := strings.Index(source, "::"); i >= 0 {
+		source = source[i+2:]
+	}
+	if i := strings.Index(source, "://"); i >= 0 {
+		source = source[i+3:]
+	}
+
+	var parts []string
+	for _, part := range strings.FieldsFunc(source, func(r rune) bool { return r == '/' || r == '?' || r == '&' }) {
+		part = localModuleDirInvalidChars.ReplaceAllString(part, "_")
+		if part != "." && part != ".." {
+			parts = append(parts, part)
+		}
+	}
+
+	if version != "" {
+		parts = append(parts, localModuleDirInvalidChars.ReplaceAllString(version, "_"))
+	}
+
+	return path.Join(parts...)
+}
@@ -0,0 +1,160 @@
+package vender_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/home-sol/homectl/internal/testutil"
+	"github.com/home-sol/homectl/pkg/config"
+	"github.com/home-sol/homectl/pkg/fs"
+)
+
+func TestVenderComponentPullLocalizeModules(t *testing.T) {
+	registry := newTestTerraformRegistry(t, "secret", "0.25.0")
+
+	vendorConfig := config.Vendor{}
+	vendorConfig.TerraformRegistry = config.TerraformRegistry{Host: registry.URL, Token: "secret"}
+
+	mux := http.NewServeMux()
+	archives := httptest.NewServer(mux)
+	t.Cleanup(archives.Close)
+
+	labelModule := `
+module "label" {
+  source  = "cloudposse/label/null"
+  version = "~> 0.25.0"
+
+  enabled = true
+}
+`
+	mux.HandleFunc("/component.tar.gz", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(testutil.TarGz(t, map[string]string{
+			"main.tf": labelModule + fmt.Sprintf(`
+module "bucket" {
+  source = "%[1]s/bucket.tar.gz"
+}
+
+module "local" {
+  source = "./modules/local"
+}
+
+module "dynamic" {
+  source = "${var.source}"
+}
+
+module "a" {
+  source = "%[1]s/modules.tar.gz//modules/a"
+}
+
+module "b" {
+  source = "%[1]s/modules.tar.gz//modules/b"
+}
+`, archives.URL),
+			"modules/local/main.tf": labelModule,
+		}))
+	})
+	// The package of the modules in the '//subdir' is downloaded once, with the modules it refers to
+	var packageRequests int32
+	mux.HandleFunc("/modules.tar.gz", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			atomic.AddInt32(&packageRequests, 1)
+		}
+		_, _ = w.Write(testutil.TarGz(t, map[string]string{
+			"modules/a/main.tf": "module \"b\" {\n  source = \"../b\"\n}\n",
+			"modules/b/main.tf": "module \"c\" {\n  source = \"../c\"\n}\n",
+			"modules/c/main.tf": labelModule,
+		}))
+	})
+	mux.HandleFunc("/bucket.tar.gz", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(testutil.TarGz(t, map[string]string{"main.tf": labelModule}))
+	})
+
+	fss, err := fs.FromDir(t.TempDir())
+	require.NoError(t, err)
+
+	spec := config.VendorComponentSpec{
+		Source: config.VendorComponentSource{
+			Uri:             archives.URL + "/component.tar.gz",
+			LocalizeModules: true,
+		},
+	}
+
+	err = newTestVender(fss, vendorConfig).ExecuteComponentVendorCommand(spec, "bucket", "bucket", false, false, "pull")
+	require.NoError(t, err)
+
+	readFile := func(name string) string {
+		content, err := ioutil.ReadFile(fss.GetRelativePath(path.Join("bucket", name)))
+		require.NoError(t, err)
+		return string(content)
+	}
+
+	labelDir := "modules/vendored/cloudposse/label/null/0.25.0"
+	bucketDir := "modules/vendored/127.0.0.1_" + archives.Listener.Addr().String()[len("127.0.0.1:"):] + "/bucket.tar.gz"
+
+	main := readFile("main.tf")
+	assert.Contains(t, main, `source = "./`+labelDir+`"`)
+	assert.Contains(t, main, `source = "./`+bucketDir+`"`)
+	assert.Contains(t, main, `source = "./modules/local"`)
+	assert.Contains(t, main, `source = "${var.source}"`)
+	assert.NotContains(t, main, "version")
+	assert.Contains(t, main, "enabled = true")
+
+	// The nested and the repeated modules use the same downloaded copy
+	assert.Contains(t, readFile("modules/local/main.tf"), `source = "../vendored/cloudposse/label/null/0.25.0"`)
+	assert.Contains(t, readFile(path.Join(bucketDir, "main.tf")), `source = "../../cloudposse/label/null/0.25.0"`)
+	assert.Equal(t, "# version 0.25.0\n", readFile(path.Join(labelDir, "main.tf")))
+
+	packageDir := "modules/vendored/127.0.0.1_" + archives.Listener.Addr().String()[len("127.0.0.1:"):] + "/modules.tar.gz"
+	assert.Contains(t, main, `source = "./`+packageDir+`/modules/a"`)
+	assert.Contains(t, main, `source = "./`+packageDir+`/modules/b"`)
+	assert.Contains(t, readFile(path.Join(packageDir, "modules/a/main.tf")), `source = "../b"`)
+	assert.Contains(t, readFile(path.Join(packageDir, "modules/c/main.tf")), `source = "../../../../cloudposse/label/null/0.25.0"`)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&packageRequests))
+}
+
+func TestVenderComponentPullLocalizeModulesOverlappingFolders(t *testing.T) {
+	mux := http.NewServeMux()
+	archives := httptest.NewServer(mux)
+	t.Cleanup(archives.Close)
+
+	mux.HandleFunc("/component.tar.gz", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(testutil.TarGz(t, map[string]string{
+			"main.tf": fmt.Sprintf(`
+module "a" {
+  source = "%[1]s/module.tar.gz"
+}
+
+module "b" {
+  source = "%[1]s/module.tar.gz?archive=tar.gz"
+}
+`, archives.URL),
+		}))
+	})
+	mux.HandleFunc("/module.tar.gz", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(testutil.TarGz(t, map[string]string{"main.tf": "# module\n"}))
+	})
+
+	fss, err := fs.FromDir(t.TempDir())
+	require.NoError(t, err)
+
+	spec := config.VendorComponentSpec{
+		Source: config.VendorComponentSource{
+			Uri:             archives.URL + "/component.tar.gz",
+			LocalizeModules: true,
+		},
+	}
+
+	// The second module would be downloaded into the folder of the first one
+	err = newTestVender(fss, config.Vendor{}).ExecuteComponentVendorCommand(spec, "bucket", "bucket", false, false, "pull")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "can't be localized into the overlapping folders")
+	assert.Contains(t, err.Error(), "/module.tar.gz/archive_tar.gz' and 'modules/vendored/")
+}
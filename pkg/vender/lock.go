@@ -0,0 +1,84 @@
+package vender
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/home-sol/homectl/pkg/fs"
+)
+
+const (
+	// repoLockFile is shared by the component operations, so the bulk operations can lock the repo exclusively
+	repoLockFile = "repo.lock"
+	// lockRetryInterval is the interval between the attempts to take the lock held by another process
+	lockRetryInterval = 100 * time.Millisecond
+)
+
+// lockComponent takes the shared repo lock and the exclusive lock of the component, waiting up to 'vendor.lock.timeout'.
+// The returned function releases both locks
+func (v *Vender) lockComponent(l *zap.SugaredLogger, componentPath string) (func(), error) {
+	repoLock, err := v.acquireLock(l, repoLockFile, false, "the repo")
+	if err != nil {
+		return nil, err
+	}
+
+	// The component path is escaped, so the lock files of all components are in the same folder
+	name := "component-" + url.PathEscape(path.Clean(filepath.ToSlash(componentPath))) + ".lock"
+
+	componentLock, err := v.acquireLock(l, name, true, fmt.Sprintf("the component '%s'", componentPath))
+	if err != nil {
+		releaseLock(l, repoLock)
+		return nil, err
+	}
+
+	return func() {
+		releaseLock(l, componentLock)
+		releaseLock(l, repoLock)
+	}, nil
+}
+
+// acquireLock takes the lock file in the 'vendor.lock.dir' folder, retrying while it's held by another process
+func (v *Vender) acquireLock(l *zap.SugaredLogger, name string, exclusive bool, what string) (fs.Lock, error) {
+	return v.waitForLock(l, v.fs, filepath.Join(filepath.FromSlash(v.config.Lock.Dir), name), exclusive, what)
+}
+
+// waitForLock takes the lock file in the file system, retrying up to 'vendor.lock.timeout' while it's held by another process
+func (v *Vender) waitForLock(l *zap.SugaredLogger, fss fs.FileSystem, name string, exclusive bool, what string) (fs.Lock, error) {
+	lockConfig := v.config.Lock
+
+	deadline := time.Now().Add(lockConfig.Timeout)
+	waiting := false
+
+	for {
+		lock, err := fss.TryLock(name, exclusive)
+		if err == nil {
+			return lock, nil
+		}
+		if !errors.Is(err, fs.ErrLocked) {
+			return nil, fmt.Errorf("failed to lock %s: %w", what, err)
+		}
+
+		if !time.Now().Before(deadline) {
+			return nil, fmt.Errorf("timed out after %s waiting for the lock of %s, check 'vendor.lock.timeout': %w", lockConfig.Timeout, what, err)
+		}
+
+		if !waiting {
+			l.Infof("Waiting for the lock of %s: %v", what, err)
+			waiting = true
+		}
+
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+func releaseLock(l *zap.SugaredLogger, lock fs.Lock) {
+	if err := lock.Unlock(); err != nil {
+		l.Error(err)
+	}
+}
@@ -0,0 +1,99 @@
+package vender_test
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/home-sol/homectl/internal/testutil"
+	"github.com/home-sol/homectl/pkg/config"
+	"github.com/home-sol/homectl/pkg/fs"
+)
+
+func TestVenderComponentPullLock(t *testing.T) {
+	server := testutil.NewArchiveServer(t, map[string]string{
+		"main.tf": "# main\n",
+	})
+
+	spec := config.VendorComponentSpec{
+		Source: config.VendorComponentSource{
+			Uri: server.URL + "/source.tar.gz",
+		},
+	}
+
+	vendorConfig := config.Vendor{}
+	vendorConfig.Lock.Dir = ".homectl/locks"
+
+	host, err := os.Hostname()
+	require.NoError(t, err)
+
+	t.Run("timeout", func(t *testing.T) {
+		fss, err := fs.FromDir(t.TempDir())
+		require.NoError(t, err)
+
+		vendorConfig.Lock.Timeout = 200 * time.Millisecond
+
+		// Another run is pulling the component
+		lock, err := fss.TryLock(".homectl/locks/component-infra%2Fvpc.lock", true)
+		require.NoError(t, err)
+		defer func() { require.NoError(t, lock.Unlock()) }()
+
+		err = newTestVender(fss, vendorConfig).ExecuteComponentVendorCommand(spec, "infra/vpc", "infra/vpc", false, false, "pull")
+		require.Error(t, err)
+		assert.ErrorIs(t, err, fs.ErrLocked)
+		assert.Contains(t, err.Error(), "timed out after 200ms waiting for the lock of the component 'infra/vpc'")
+		assert.Contains(t, err.Error(), fmt.Sprintf("locked by the process %d on the host '%s'", os.Getpid(), host))
+		assert.NoFileExists(t, fss.GetRelativePath("infra/vpc/main.tf"))
+
+		// The other components are not locked
+		require.NoError(t, newTestVender(fss, vendorConfig).ExecuteComponentVendorCommand(spec, "infra/dns", "infra/dns", false, false, "pull"))
+		assert.FileExists(t, fss.GetRelativePath("infra/dns/main.tf"))
+	})
+
+	t.Run("repo", func(t *testing.T) {
+		fss, err := fs.FromDir(t.TempDir())
+		require.NoError(t, err)
+
+		vendorConfig.Lock.Timeout = 0
+
+		// A bulk operation is running
+		lock, err := fss.TryLock(".homectl/locks/repo.lock", true)
+		require.NoError(t, err)
+		defer func() { require.NoError(t, lock.Unlock()) }()
+
+		err = newTestVender(fss, vendorConfig).ExecuteComponentVendorCommand(spec, "infra/vpc", "infra/vpc", false, false, "pull")
+		assert.ErrorIs(t, err, fs.ErrLocked)
+		assert.Contains(t, err.Error(), "waiting for the lock of the repo")
+
+		// The dry run doesn't write anything, so it doesn't wait for the lock
+		assert.NoError(t, newTestVender(fss, vendorConfig).ExecuteComponentVendorCommand(spec, "infra/vpc", "infra/vpc", true, false, "pull"))
+
+		// The stack vendoring is not implemented yet, so it fails without taking the lock
+		err = newTestVender(fss, vendorConfig).ExecuteStackVendorCommand("home-main-prod", false, "pull")
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, fs.ErrLocked)
+		assert.Contains(t, err.Error(), "is not implemented yet")
+	})
+
+	t.Run("wait", func(t *testing.T) {
+		fss, err := fs.FromDir(t.TempDir())
+		require.NoError(t, err)
+
+		vendorConfig.Lock.Timeout = 10 * time.Second
+
+		lock, err := fss.TryLock(".homectl/locks/component-infra%2Fvpc.lock", true)
+		require.NoError(t, err)
+
+		go func() {
+			time.Sleep(300 * time.Millisecond)
+			_ = lock.Unlock()
+		}()
+
+		require.NoError(t, newTestVender(fss, vendorConfig).ExecuteComponentVendorCommand(spec, "infra/vpc", "infra/vpc", false, false, "pull"))
+		assert.FileExists(t, fss.GetRelativePath("infra/vpc/main.tf"))
+	})
+}
@@ -0,0 +1,177 @@
+package vender
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+
+	"github.com/home-sol/homectl/pkg/config"
+)
+
+// pathMapping moves the files matching the 'source' pattern from 'mappings' to the 'target' path in the component
+type pathMapping struct {
+	source string
+	target string
+	// The 'source' pattern has wildcards
+	glob bool
+	// The folder of the 'source' pattern before the first wildcard, the matching files keep their paths relative to it
+	base string
+	// The 'target' is a folder ('./', '.' or a path ending with '/')
+	targetDir bool
+}
+
+func (m pathMapping) String() string {
+	return fmt.Sprintf("mappings: %s -> %s", m.source, m.target)
+}
+
+func newPathMapping(mapping config.VendorComponentMapping) (pathMapping, error) {
+	m := pathMapping{
+		source: mapping.Source,
+		target: mapping.Target,
+	}
+
+	source := strings.Trim(strings.TrimPrefix(mapping.Source, "./"), "/")
+	if source == "" || source == "." || !doublestar.ValidatePattern(source) {
+		return m, fmt.Errorf("invalid 'source' '%s' in 'mappings'", mapping.Source)
+	}
+
+	target := path.Clean(strings.TrimPrefix(mapping.Target, "/"))
+	if target == ".." || strings.HasPrefix(target, "../") {
+		return m, fmt.Errorf("'target' '%s' in 'mappings' must be inside the component folder", mapping.Target)
+	}
+
+	m.targetDir = target == "." || strings.HasSuffix(mapping.Target, "/")
+	m.glob = strings.ContainsAny(source, "*?[{\\")
+
+	if m.glob {
+		var dirs []string
+		for _, segment := range strings.Split(source, "/") {
+			if strings.ContainsAny(segment, "*?[{\\") {
+				break
+			}
+			dirs = append(dirs, segment)
+		}
+		m.base = path.Join(dirs...)
+	}
+
+	m.source = source
+	m.target = target
+	return m, nil
+}
+
+// apply returns the path of the file in the component if the mapping matches the file (or any of its parent folders)
+func (m pathMapping) apply(file string) (string, bool) {
+	if !m.glob {
+		if file == m.source {
+			if m.targetDir {
+				return path.Join(m.target, path.Base(file)), true
+			}
+			return m.target, true
+		}
+		if strings.HasPrefix(file, m.source+"/") {
+			return path.Join(m.target, strings.TrimPrefix(file, m.source+"/")), true
+		}
+		return "", false
+	}
+
+	for p := file; p != "."; p = path.Dir(p) {
+		if ok, _ := doublestar.Match(m.source, p); ok {
+			rel := file
+			if m.base != "" {
+				rel = strings.TrimPrefix(file, m.base+"/")
+			}
+			return path.Join(m.target, rel), true
+		}
+	}
+
+	return "", false
+}
+
+// mapPath returns the path of the source file in the component using the first matching mapping.
+// The files not matching any mapping keep their paths
+func mapPath(mappings []pathMapping, file string) string {
+	for _, m := range mappings {
+		if target, ok := m.apply(file); ok {
+			return target
+		}
+	}
+	return file
+}
+
+func newPathMappings(source config.VendorComponentSource) ([]pathMapping, error) {
+	var mappings []pathMapping
+
+	for _, mapping := range source.Mappings {
+		m, err := newPathMapping(mapping)
+		if err != nil {
+			return nil, err
+		}
+		mappings = append(mappings, m)
+	}
+
+	return mappings, nil
+}
+
+// contentRewrite replaces the 'regex' or 'literal' with 'replace' in the files matching the 'paths' patterns
+type contentRewrite struct {
+	paths   []pathRule
+	regex   *regexp.Regexp
+	literal string
+	replace string
+}
+
+func newContentRewrites(source config.VendorComponentSource) ([]contentRewrite, error) {
+	var rewrites []contentRewrite
+
+	for _, rewrite := range source.Rewrites {
+		r := contentRewrite{
+			literal: rewrite.Literal,
+			replace: rewrite.Replace,
+		}
+
+		if (rewrite.Regex == "") == (rewrite.Literal == "") {
+			return nil, fmt.Errorf("either 'regex' or 'literal' needs to be specified for each rewrite in 'rewrites', but not both")
+		}
+
+		if rewrite.Regex != "" {
+			regex, err := regexp.Compile(rewrite.Regex)
+			if err != nil {
+				return nil, fmt.Errorf("invalid 'regex' '%s' in 'rewrites': %w", rewrite.Regex, err)
+			}
+			r.regex = regex
+		}
+
+		for _, pattern := range rewrite.Paths {
+			rule, err := newPathRule("rewrites", pattern)
+			if err != nil {
+				return nil, err
+			}
+			r.paths = append(r.paths, rule)
+		}
+
+		rewrites = append(rewrites, r)
+	}
+
+	return rewrites, nil
+}
+
+// matches checks if the rewrite applies to the file (relative to the component folder).
+// Without 'paths', the rewrite applies to all files
+func (r contentRewrite) matches(file string) bool {
+	if len(r.paths) == 0 {
+		return true
+	}
+
+	rule := lastMatch(r.paths, file)
+	return rule != nil && !rule.negate
+}
+
+func (r contentRewrite) apply(content []byte) []byte {
+	if r.regex != nil {
+		return r.regex.ReplaceAll(content, []byte(r.replace))
+	}
+	return []byte(strings.ReplaceAll(string(content), r.literal, r.replace))
+}
@@ -0,0 +1,103 @@
+package vender_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/home-sol/homectl/internal/testutil"
+	"github.com/home-sol/homectl/pkg/config"
+	"github.com/home-sol/homectl/pkg/fs"
+)
+
+func TestVenderComponentPullMappingsAndRewrites(t *testing.T) {
+	server := testutil.NewArchiveServer(t, map[string]string{
+		"modules/foo/main.tf":           "module \"account_map\" {\n  source = \"../account-map\"\n}\n",
+		"modules/foo/variables.tf":      "variable \"region\" {}\n",
+		"modules/foo/files/policy.json": "{}\n",
+		"modules/bar/main.tf":           "# bar\n",
+		"docs/README.md":                "# foo\n",
+	})
+
+	spec := config.VendorComponentSpec{
+		Source: config.VendorComponentSource{
+			Uri:           server.URL + "/source.tar.gz",
+			ExcludedPaths: []string{"modules/bar/"},
+			Mappings: []config.VendorComponentMapping{
+				{Source: "modules/foo/*", Target: "./"},
+				{Source: "docs/README.md", Target: "README.md"},
+			},
+			Rewrites: []config.VendorComponentRewrite{
+				{
+					Paths:   []string{"*.tf"},
+					Regex:   `source\s*=\s*"\.\./account-map"`,
+					Replace: `source = "../../infra/account-map"`,
+				},
+				{
+					Paths:   []string{"README.md"},
+					Literal: "foo",
+					Replace: "bar",
+				},
+			},
+		},
+	}
+
+	fss, err := fs.FromDir(t.TempDir())
+	require.NoError(t, err)
+
+	// The diff shows the files that would be pulled with the 'mappings' and 'rewrites' applied
+	var out bytes.Buffer
+	require.NoError(t, newTestVender(fss, config.Vendor{}).DiffComponentVendorFiles(spec, "test", "test", &out))
+	assert.Contains(t, out.String(), "--- /dev/null\n+++ b/main.tf\n")
+	assert.Contains(t, out.String(), "+  source = \"../../infra/account-map\"\n")
+	assert.Contains(t, out.String(), "+++ b/files/policy.json\n")
+	assert.NotContains(t, out.String(), "modules/")
+
+	require.NoError(t, newTestVender(fss, config.Vendor{}).ExecuteComponentVendorCommand(spec, "test", "test", false, false, "pull"))
+
+	read := func(file string) string {
+		content, err := ioutil.ReadFile(fss.GetRelativePath(path.Join("test", file)))
+		require.NoError(t, err)
+		return string(content)
+	}
+
+	assert.Equal(t, "module \"account_map\" {\n  source = \"../../infra/account-map\"\n}\n", read("main.tf"))
+	assert.Equal(t, "variable \"region\" {}\n", read("variables.tf"))
+	assert.Equal(t, "{}\n", read("files/policy.json"))
+	assert.Equal(t, "# bar\n", read("README.md"))
+	assert.NoDirExists(t, fss.GetRelativePath("test/modules"))
+	assert.NoDirExists(t, fss.GetRelativePath("test/docs"))
+
+	// Nothing to pull after the pull
+	out.Reset()
+	require.NoError(t, newTestVender(fss, config.Vendor{}).DiffComponentVendorFiles(spec, "test", "test", &out))
+	assert.Empty(t, out.String())
+}
+
+func TestVenderComponentPullMappingsConflict(t *testing.T) {
+	server := testutil.NewArchiveServer(t, map[string]string{
+		"modules/foo/main.tf": "",
+		"modules/bar/main.tf": "",
+	})
+
+	spec := config.VendorComponentSpec{
+		Source: config.VendorComponentSource{
+			Uri: server.URL + "/source.tar.gz",
+			Mappings: []config.VendorComponentMapping{
+				{Source: "modules/foo/*", Target: "./"},
+				{Source: "modules/bar/*", Target: "./"},
+			},
+		},
+	}
+
+	fss, err := fs.FromDir(t.TempDir())
+	require.NoError(t, err)
+
+	err = newTestVender(fss, config.Vendor{}).ExecuteComponentVendorCommand(spec, "test", "test", false, false, "pull")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "mapped to the same path")
+}
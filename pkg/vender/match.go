@@ -0,0 +1,226 @@
+package vender
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+
+	"github.com/home-sol/homectl/pkg/config"
+)
+
+// pathRule is a gitignore-style pattern from 'included_paths' or 'excluded_paths'
+// https://git-scm.com/docs/gitignore#_pattern_format
+type pathRule struct {
+	// Name of the list the rule is declared in ('included_paths' or 'excluded_paths')
+	list string
+	// Pattern as declared in the list
+	pattern string
+	// Doublestar pattern matched against the paths relative to the source root
+	glob string
+	// The pattern starts with '!'
+	negate bool
+	// The pattern ends with '/' and only matches folders
+	dirOnly bool
+}
+
+func (r pathRule) String() string {
+	return fmt.Sprintf("%s: %s", r.list, r.pattern)
+}
+
+// newPathRule parses the gitignore-style pattern:
+//   - '!' at the beginning negates the pattern. Same as in git, the files in a matched folder can't be negated
+//   - '/' at the end matches only folders (and all files in them)
+//   - Patterns with a '/' at the beginning or in the middle are anchored to the source root.
+//     Other patterns match at any depth (e.g. '*.tf' is the same as '**/*.tf')
+func newPathRule(list string, pattern string) (pathRule, error) {
+	rule := pathRule{list: list, pattern: pattern}
+
+	p := strings.TrimSpace(pattern)
+	if strings.HasPrefix(p, "!") {
+		rule.negate = true
+		p = p[1:]
+	}
+	if strings.HasSuffix(p, "/") {
+		rule.dirOnly = true
+		p = strings.TrimRight(p, "/")
+	}
+
+	if p == "" {
+		return rule, fmt.Errorf("invalid pattern '%s' in '%s'", pattern, list)
+	}
+
+	if strings.Contains(p, "/") {
+		p = strings.TrimPrefix(p, "/")
+	} else {
+		p = "**/" + p
+	}
+
+	if !doublestar.ValidatePattern(p) {
+		return rule, fmt.Errorf("invalid pattern '%s' in '%s'", pattern, list)
+	}
+
+	rule.glob = p
+	return rule, nil
+}
+
+// matches checks if the rule matches the file or the folder itself (folder patterns only match folders)
+func (r pathRule) matches(p string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if ok, _ := doublestar.Match(r.glob, p); !ok {
+		return false
+	}
+
+	// Same as in git, 'foo/**' matches everything inside 'foo', but not 'foo' itself.
+	// The path matches if any of its parent folders matches 'foo' (e.g. 'test/test' for '**/test/**')
+	if prefix := strings.TrimSuffix(r.glob, "/**"); prefix != r.glob {
+		for dir := path.Dir(p); dir != "." && dir != "/"; dir = path.Dir(dir) {
+			if ok, _ := doublestar.Match(prefix, dir); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+// PathDecision describes if a file from the source is vendored and which rule decided it
+type PathDecision struct {
+	// Path of the file relative to the source root
+	Path     string
+	Included bool
+	// The rule that decided, or the reason if no rule matched the file
+	Reason string
+}
+
+// pathMatcher decides which files from the source are vendored using the 'included_paths' and 'excluded_paths' rules
+type pathMatcher struct {
+	included []pathRule
+	excluded []pathRule
+}
+
+func newPathMatcher(source config.VendorComponentSource) (*pathMatcher, error) {
+	m := &pathMatcher{}
+
+	for _, pattern := range source.IncludedPaths {
+		rule, err := newPathRule("included_paths", pattern)
+		if err != nil {
+			return nil, err
+		}
+		m.included = append(m.included, rule)
+	}
+
+	for _, pattern := range source.ExcludedPaths {
+		rule, err := newPathRule("excluded_paths", pattern)
+		if err != nil {
+			return nil, err
+		}
+		m.excluded = append(m.excluded, rule)
+	}
+
+	return m, nil
+}
+
+// lastMatch returns the rule that decides the file, same as in '.gitignore' files: the last matching rule wins, and the parent
+// folders are checked first. If a folder is matched (by a rule that is not negated), the files in it can't be un-matched
+// by the negated patterns, since git doesn't look into the matched folders. The folder has to be negated first (e.g. '!modules/bar/')
+func lastMatch(rules []pathRule, file string) *pathRule {
+	var dirs []string
+	for dir := path.Dir(file); dir != "."; dir = path.Dir(dir) {
+		dirs = append([]string{dir}, dirs...)
+	}
+
+	for _, dir := range dirs {
+		if rule := lastMatchPath(rules, dir, true); rule != nil && !rule.negate {
+			return rule
+		}
+	}
+
+	return lastMatchPath(rules, file, false)
+}
+
+// lastMatchPath returns the last rule in the list that matches the file or the folder itself
+func lastMatchPath(rules []pathRule, p string, isDir bool) *pathRule {
+	for i := len(rules) - 1; i >= 0; i-- {
+		if rules[i].matches(p, isDir) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+// Match decides if the file (relative to the source root, with '/' separators) is vendored.
+// The file is vendored if it is included (by the last matching 'included_paths' rule, or because 'included_paths'
+// is empty) and not excluded (by the last matching 'excluded_paths' rule)
+func (m *pathMatcher) Match(file string) PathDecision {
+	decision := PathDecision{Path: file}
+
+	excludeRule := lastMatch(m.excluded, file)
+	if excludeRule != nil && !excludeRule.negate {
+		decision.Reason = excludeRule.String()
+		return decision
+	}
+
+	if len(m.included) == 0 {
+		decision.Included = true
+		decision.Reason = "'included_paths' is not specified"
+		if excludeRule != nil {
+			decision.Reason = excludeRule.String()
+		}
+		return decision
+	}
+
+	includeRule := lastMatch(m.included, file)
+	if includeRule == nil {
+		decision.Reason = "does not match any pattern from 'included_paths'"
+		return decision
+	}
+
+	decision.Included = !includeRule.negate
+	decision.Reason = includeRule.String()
+	return decision
+}
+
+// matchSourceFiles walks the source folder and decides for each file if it is vendored.
+// The decisions are sorted by path. The '.git' folders and the root '.vendor' folder are skipped
+func matchSourceFiles(m *pathMatcher, sourceDir string) ([]PathDecision, error) {
+	var decisions []PathDecision
+
+	err := filepath.Walk(sourceDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			// The '.vendor' folder is reserved for the state of the vendored files (e.g. the merge base)
+			if info.Name() == ".git" || (info.Name() == ".vendor" && filepath.Dir(p) == filepath.Clean(sourceDir)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(sourceDir, p)
+		if err != nil {
+			return err
+		}
+
+		decisions = append(decisions, m.Match(filepath.ToSlash(rel)))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(decisions, func(i, j int) bool {
+		return decisions[i].Path < decisions[j].Path
+	})
+
+	return decisions, nil
+}
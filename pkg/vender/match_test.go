@@ -0,0 +1,130 @@
+package vender_test
+
+import (
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/home-sol/homectl/internal/testutil"
+	"github.com/home-sol/homectl/pkg/config"
+	"github.com/home-sol/homectl/pkg/fs"
+	"github.com/home-sol/homectl/pkg/vender"
+)
+
+func TestListComponentVendorFiles(t *testing.T) {
+	server := testutil.NewArchiveServer(t, map[string]string{
+		"main.tf":                    "",
+		"context.tf":                 "",
+		"README.md":                  "",
+		"docs/diagram.png":           "",
+		"modules/foo/main.tf":        "",
+		"modules/foo/README.md":      "",
+		"modules/foo/test/main.tf":   "",
+		"modules/bar/main.tf":        "",
+		"modules/bar/fixtures/a.tf":  "",
+		"examples/complete/main.tf":  "",
+		"examples/complete/vars.tf":  "",
+		"examples/complete/keep.txt": "",
+	})
+
+	spec := config.VendorComponentSpec{
+		Source: config.VendorComponentSource{
+			Uri: server.URL + "/source.tar.gz",
+			IncludedPaths: []string{
+				// Unanchored patterns match at any depth
+				"*.tf",
+				"*.md",
+				// Anchored patterns match relative to the source root
+				"/examples/complete/keep.txt",
+			},
+			ExcludedPaths: []string{
+				"context.tf",
+				// Folder patterns exclude all files in the folder
+				"test/",
+				"modules/bar/",
+				// Same as in git, the files in an excluded folder can't be re-included
+				"!modules/bar/main.tf",
+				// Negated patterns re-include the files excluded by the previous patterns, after re-including their folder
+				"/examples/**",
+				"!/examples/complete/",
+				"!/examples/complete/keep.txt",
+			},
+		},
+	}
+
+	decisions, err := newTestVender(fs.NewMemFileSystem(), config.Vendor{}).ListComponentVendorFiles(spec, "test")
+	require.NoError(t, err)
+
+	expected := map[string]vender.PathDecision{
+		"README.md":                  {Included: true, Reason: "included_paths: *.md"},
+		"context.tf":                 {Included: false, Reason: "excluded_paths: context.tf"},
+		"docs/diagram.png":           {Included: false, Reason: "does not match any pattern from 'included_paths'"},
+		"examples/complete/keep.txt": {Included: true, Reason: "included_paths: /examples/complete/keep.txt"},
+		"examples/complete/main.tf":  {Included: false, Reason: "excluded_paths: /examples/**"},
+		"examples/complete/vars.tf":  {Included: false, Reason: "excluded_paths: /examples/**"},
+		"main.tf":                    {Included: true, Reason: "included_paths: *.tf"},
+		"modules/bar/fixtures/a.tf":  {Included: false, Reason: "excluded_paths: modules/bar/"},
+		"modules/bar/main.tf":        {Included: false, Reason: "excluded_paths: modules/bar/"},
+		"modules/foo/README.md":      {Included: true, Reason: "included_paths: *.md"},
+		"modules/foo/main.tf":        {Included: true, Reason: "included_paths: *.tf"},
+		"modules/foo/test/main.tf":   {Included: false, Reason: "excluded_paths: test/"},
+	}
+
+	require.Len(t, decisions, len(expected))
+	for _, decision := range decisions {
+		e, ok := expected[decision.Path]
+		require.True(t, ok, decision.Path)
+		assert.Equal(t, e.Included, decision.Included, decision.Path)
+		assert.Equal(t, e.Reason, decision.Reason, decision.Path)
+	}
+
+	// Folders with included files are copied without listing them in 'included_paths'
+	fss, err := fs.FromDir(t.TempDir())
+	require.NoError(t, err)
+
+	err = newTestVender(fss, config.Vendor{}).ExecuteComponentVendorCommand(spec, "test", "test", false, false, "pull")
+	require.NoError(t, err)
+
+	for file, decision := range expected {
+		if decision.Included {
+			assert.FileExists(t, fss.GetRelativePath(path.Join("test", file)))
+		} else {
+			assert.NoFileExists(t, fss.GetRelativePath(path.Join("test", file)))
+		}
+	}
+	assert.NoDirExists(t, fss.GetRelativePath("test/docs"))
+	assert.NoDirExists(t, fss.GetRelativePath("test/modules/foo/test"))
+}
+
+func TestListComponentVendorFilesInsideFolders(t *testing.T) {
+	server := testutil.NewArchiveServer(t, map[string]string{
+		"main.tf":              "",
+		"test/test":            "",
+		"modules/test/main.tf": "",
+		"docs/test":            "",
+	})
+
+	spec := config.VendorComponentSpec{
+		Source: config.VendorComponentSource{
+			Uri: server.URL + "/source.tar.gz",
+			// 'test/**' matches the files inside the 'test' folders, including the ones named 'test'
+			ExcludedPaths: []string{"**/test/**"},
+		},
+	}
+
+	decisions, err := newTestVender(fs.NewMemFileSystem(), config.Vendor{}).ListComponentVendorFiles(spec, "test")
+	require.NoError(t, err)
+
+	included := map[string]bool{}
+	for _, decision := range decisions {
+		included[decision.Path] = decision.Included
+	}
+	assert.Equal(t, map[string]bool{
+		"main.tf":              true,
+		"test/test":            false,
+		"modules/test/main.tf": false,
+		"docs/test":            true,
+	}, included)
+}
@@ -0,0 +1,285 @@
+package vender
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/home-sol/homectl/pkg/diff"
+	"github.com/home-sol/homectl/pkg/fs"
+)
+
+// vendorBaseDir is the folder in the component with the files as they were vendored by the last pull.
+// It is the merge base for the three-way merge of the upstream changes with the local modifications
+var vendorBaseDir = filepath.Join(".vendor", "base")
+
+// mergeAction is the change of a file in the component folder made by the merge
+type mergeAction struct {
+	// Path of the file relative to the component folder
+	file string
+	// Content of the local file (nil if the file does not exist)
+	local []byte
+	// Content of the file after the merge
+	content []byte
+	mode    os.FileMode
+	// The file is a symlink and 'content' is its target
+	link bool
+	// The file is removed upstream and not modified locally
+	remove bool
+	// The upstream changes conflict with the local modifications
+	conflict bool
+}
+
+// planMerge merges the vendored files from the staging folder with the files in the component folder.
+// The files that were not modified locally are replaced with the new upstream version, the files that were not changed
+// upstream keep the local modifications, and the files changed on both sides are merged line by line
+// (the conflicts are written with the conflict markers, binary files and symlinks keep the local version).
+// Returns the actions for the files that are changed or have conflicts
+func planMerge(l *zap.SugaredLogger, staging fs.FileSystem, component fs.FileSystem) ([]mergeAction, error) {
+	// Without the merge base (the component was never pulled before), the upstream files overwrite the local files
+	hasBase := true
+	if _, err := component.Stat(vendorBaseDir); errors.Is(err, os.ErrNotExist) {
+		hasBase = false
+	} else if err != nil {
+		return nil, err
+	}
+
+	files, err := listFiles(staging, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	var actions []mergeAction
+	upstreamFiles := map[string]bool{}
+
+	for _, file := range files {
+		upstreamFiles[file] = true
+
+		info, err := staging.Lstat(file)
+		if err != nil {
+			return nil, err
+		}
+
+		upstream, upstreamLink, err := readEntry(staging, file)
+		if err != nil {
+			return nil, err
+		}
+
+		action := mergeAction{file: file, content: upstream, mode: info.Mode().Perm(), link: upstreamLink}
+
+		local, localLink, err := readEntry(component, file)
+		if errors.Is(err, os.ErrNotExist) || !hasBase {
+			action.local = local
+			actions = append(actions, action)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		action.local = local
+		if sameEntry(local, localLink, upstream, upstreamLink) {
+			continue
+		}
+
+		// The file added upstream that already exists locally is merged with an empty base
+		base, baseLink, err := readEntry(component, filepath.Join(vendorBaseDir, file))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+
+		switch {
+		case sameEntry(local, localLink, base, baseLink):
+		case sameEntry(upstream, upstreamLink, base, baseLink):
+			l.Infow("Keeping the local modifications", "file", file)
+			continue
+		case localLink || upstreamLink || baseLink:
+			l.Warnw("The symlink is modified locally and upstream, keeping the local version", "file", file)
+			action.content = local
+			action.link = localLink
+			action.conflict = true
+		case diff.IsBinary(base) || diff.IsBinary(local) || diff.IsBinary(upstream):
+			l.Warnw("The binary file is modified locally and upstream, keeping the local version", "file", file)
+			action.content = local
+			action.conflict = true
+		default:
+			action.content, action.conflict = diff.Merge3(base, local, upstream, "local", "upstream")
+			if action.conflict {
+				l.Warnw("The upstream changes conflict with the local modifications", "file", file)
+			} else {
+				l.Infow("Merged the upstream changes with the local modifications", "file", file)
+			}
+		}
+
+		actions = append(actions, action)
+	}
+
+	// The files removed upstream are removed from the component if they were not modified locally
+	if hasBase {
+		baseFiles, err := listFiles(component, vendorBaseDir)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range baseFiles {
+			if upstreamFiles[file] {
+				continue
+			}
+
+			local, localLink, err := readEntry(component, file)
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			base, baseLink, err := readEntry(component, filepath.Join(vendorBaseDir, file))
+			if err != nil {
+				return nil, err
+			}
+
+			if !sameEntry(local, localLink, base, baseLink) {
+				l.Warnw("The file is removed upstream but modified locally, keeping the local version", "file", file)
+				continue
+			}
+
+			actions = append(actions, mergeAction{file: file, local: local, link: localLink, remove: true})
+		}
+	}
+
+	return actions, nil
+}
+
+// applyMerge writes the merged files into the component folder and keeps the staged files as the merge base for the
+// next pull. Returns the files with conflicts
+func applyMerge(actions []mergeAction, staging fs.FileSystem, component fs.FileSystem) ([]string, error) {
+	// The merge base folder is checked before any file is written
+	if _, err := component.SecurePath(vendorBaseDir); err != nil {
+		return nil, err
+	}
+
+	var conflicts []string
+
+	for _, action := range actions {
+		if action.conflict {
+			conflicts = append(conflicts, action.file)
+		}
+
+		if action.remove {
+			if err := component.Remove(action.file); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if action.link {
+			if err := component.Symlink(string(action.content), action.file); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// The local symlink is replaced, not written through
+		if err := component.WriteFile(action.file, action.content, action.mode); err != nil {
+			return nil, err
+		}
+	}
+
+	// The new upstream version is the merge base for the next pull
+	if err := component.RemoveAll(vendorBaseDir); err != nil {
+		return nil, err
+	}
+
+	files, err := listFiles(staging, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		info, err := staging.Lstat(file)
+		if err != nil {
+			return nil, err
+		}
+
+		content, link, err := readEntry(staging, file)
+		if err != nil {
+			return nil, err
+		}
+
+		base := filepath.Join(vendorBaseDir, file)
+		if link {
+			err = component.Symlink(string(content), base)
+		} else {
+			err = component.WriteFile(base, content, info.Mode().Perm())
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return conflicts, nil
+}
+
+// conflictsError returns the error listing the files with merge conflicts
+func conflictsError(conflicts []string) error {
+	return fmt.Errorf(
+		"the upstream changes conflict with the local modifications in the files:\n  %s\nresolve the conflicts between the '<<<<<<< local' and '>>>>>>> upstream' markers",
+		strings.Join(conflicts, "\n  "),
+	)
+}
+
+// listFiles returns the files in the folder (relative to the folder), sorted by path
+func listFiles(fsys fs.FileSystem, dir string) ([]string, error) {
+	var files []string
+
+	err := fsys.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+
+		files = append(files, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// readEntry returns the content of the file, or the target of the symlink
+func readEntry(fsys fs.FileSystem, name string) ([]byte, bool, error) {
+	info, err := fsys.Lstat(name)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := fsys.Readlink(name)
+		return []byte(target), true, err
+	}
+
+	content, err := fsys.ReadFile(name)
+	return content, false, err
+}
+
+// sameEntry checks if both entries are files with the same content, or symlinks with the same target
+func sameEntry(a []byte, aLink bool, b []byte, bLink bool) bool {
+	return aLink == bLink && bytes.Equal(a, b)
+}
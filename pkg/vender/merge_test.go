@@ -0,0 +1,78 @@
+package vender_test
+
+import (
+	"io/ioutil"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/home-sol/homectl/internal/testutil"
+	"github.com/home-sol/homectl/pkg/config"
+	"github.com/home-sol/homectl/pkg/fs"
+)
+
+func TestVenderComponentPullMerge(t *testing.T) {
+	fss, err := fs.FromDir(t.TempDir())
+	require.NoError(t, err)
+
+	pull := func(files map[string]string) error {
+		server := testutil.NewArchiveServer(t, files)
+		spec := config.VendorComponentSpec{
+			Source: config.VendorComponentSource{
+				Uri: server.URL + "/source.tar.gz",
+			},
+		}
+		return newTestVender(fss, config.Vendor{}).ExecuteComponentVendorCommand(spec, "test", "test", false, false, "pull")
+	}
+
+	read := func(file string) string {
+		content, err := ioutil.ReadFile(fss.GetRelativePath(path.Join("test", file)))
+		require.NoError(t, err)
+		return string(content)
+	}
+
+	write := func(file string, content string) {
+		require.NoError(t, ioutil.WriteFile(fss.GetRelativePath(path.Join("test", file)), []byte(content), 0644))
+	}
+
+	require.NoError(t, pull(map[string]string{
+		"main.tf":      "a\nb\nc\nd\ne\n",
+		"variables.tf": "a\nb\nc\n",
+		"outputs.tf":   "a\n",
+		"removed.tf":   "a\n",
+		"modified.tf":  "a\n",
+	}))
+
+	write("main.tf", "a\nb\nc\nD\ne\n")
+	write("variables.tf", "a\nlocal\nc\n")
+	write("modified.tf", "local\n")
+
+	err = pull(map[string]string{
+		"main.tf":      "A\nb\nc\nd\ne\n",
+		"variables.tf": "a\nupstream\nc\n",
+		"outputs.tf":   "upstream\n",
+		"modified.tf":  "a\n",
+		"versions.tf":  "upstream\n",
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "variables.tf")
+	assert.NotContains(t, err.Error(), "main.tf")
+
+	// Clean merge
+	assert.Equal(t, "A\nb\nc\nD\ne\n", read("main.tf"))
+	// Conflict
+	assert.Equal(t, "a\n<<<<<<< local\nlocal\n=======\nupstream\n>>>>>>> upstream\nc\n", read("variables.tf"))
+	// Not modified locally
+	assert.Equal(t, "upstream\n", read("outputs.tf"))
+	// Not changed upstream
+	assert.Equal(t, "local\n", read("modified.tf"))
+	// Added upstream
+	assert.Equal(t, "upstream\n", read("versions.tf"))
+	// Removed upstream and not modified locally
+	assert.NoFileExists(t, fss.GetRelativePath("test/removed.tf"))
+
+	// The new upstream version is the merge base for the next pull
+	assert.Equal(t, "a\nupstream\nc\n", read(".vendor/base/variables.tf"))
+}
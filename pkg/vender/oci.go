@@ -0,0 +1,550 @@
+package vender
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+
+	"github.com/home-sol/homectl/pkg/fs"
+)
+
+const (
+	ociScheme = "oci://"
+
+	ociMediaTypeImageManifest         = "application/vnd.oci.image.manifest.v1+json"
+	ociMediaTypeImageIndex            = "application/vnd.oci.image.index.v1+json"
+	dockerMediaTypeManifest           = "application/vnd.docker.distribution.manifest.v2+json"
+	dockerMediaTypeManifestList       = "application/vnd.docker.distribution.manifest.list.v2+json"
+	ociAnnotationTitle                = "org.opencontainers.image.title"
+	ociDefaultTag                     = "latest"
+	ociMaxManifestSize          int64 = 4 * 1024 * 1024
+)
+
+// ociReference is a parsed 'oci://registry/repository:tag' or 'oci://registry/repository@digest' reference
+type ociReference struct {
+	Registry   string
+	Repository string
+	// Tag or digest of the artifact
+	Reference string
+}
+
+func (r ociReference) IsDigest() bool {
+	return strings.Contains(r.Reference, ":")
+}
+
+func (r ociReference) String() string {
+	if r.IsDigest() {
+		return fmt.Sprintf("%s/%s@%s", r.Registry, r.Repository, r.Reference)
+	}
+	return fmt.Sprintf("%s/%s:%s", r.Registry, r.Repository, r.Reference)
+}
+
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// ociManifest holds the fields of an image manifest and an image index that are used for vendoring
+type ociManifest struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	MediaType     string          `json:"mediaType"`
+	Layers        []ociDescriptor `json:"layers"`
+	Manifests     []ociDescriptor `json:"manifests"`
+}
+
+// parseOciReference parses the 'oci://' source uri
+func parseOciReference(uri string) (ociReference, error) {
+	var ref ociReference
+
+	if !strings.HasPrefix(uri, ociScheme) {
+		return ref, fmt.Errorf("invalid OCI reference '%s'. The reference must start with '%s'", uri, ociScheme)
+	}
+
+	rest := strings.TrimPrefix(uri, ociScheme)
+	slash := strings.Index(rest, "/")
+	if slash <= 0 || slash == len(rest)-1 {
+		return ref, fmt.Errorf("invalid OCI reference '%s'. Expected 'oci://registry/repository:tag' or 'oci://registry/repository@digest'", uri)
+	}
+
+	ref.Registry = rest[:slash]
+	ref.Repository = rest[slash+1:]
+
+	if at := strings.Index(ref.Repository, "@"); at >= 0 {
+		ref.Reference = ref.Repository[at+1:]
+		ref.Repository = ref.Repository[:at]
+		if _, _, err := parseDigest(ref.Reference); err != nil {
+			return ref, err
+		}
+	} else if colon := strings.LastIndex(ref.Repository, ":"); colon > strings.LastIndex(ref.Repository, "/") {
+		ref.Reference = ref.Repository[colon+1:]
+		ref.Repository = ref.Repository[:colon]
+	} else {
+		ref.Reference = ociDefaultTag
+	}
+
+	if ref.Repository == "" || ref.Reference == "" {
+		return ref, fmt.Errorf("invalid OCI reference '%s'", uri)
+	}
+
+	return ref, nil
+}
+
+// parseDigest splits the digest into the algorithm and the hex-encoded value
+func parseDigest(digest string) (string, string, error) {
+	parts := strings.SplitN(digest, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid digest '%s'", digest)
+	}
+
+	switch parts[0] {
+	case "sha256", "sha512":
+		return parts[0], parts[1], nil
+	default:
+		return "", "", fmt.Errorf("digest algorithm '%s' is not supported. Supported algorithms: 'sha256', 'sha512'", parts[0])
+	}
+}
+
+// newDigester returns the hash function for the digest algorithm
+func newDigester(digest string) (hash.Hash, string, error) {
+	algorithm, encoded, err := parseDigest(digest)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if algorithm == "sha512" {
+		return sha512.New(), encoded, nil
+	}
+	return sha256.New(), encoded, nil
+}
+
+// verifyDigest checks that the content matches the digest
+func verifyDigest(digest string, content []byte) error {
+	h, encoded, err := newDigester(digest)
+	if err != nil {
+		return err
+	}
+
+	h.Write(content)
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != encoded {
+		return fmt.Errorf("digest mismatch: expected '%s', got '%s'", encoded, actual)
+	}
+
+	return nil
+}
+
+// downloadOciSource pulls the artifact referenced by 'uri' from an OCI registry, verifies the digests of the manifest and
+// the layers, and unpacks the layers into the 'dst' folder
+// https://github.com/opencontainers/distribution-spec/blob/main/spec.md#pulling-manifests
+func (v *Vender) downloadOciSource(ctx context.Context, uri string, dst string) error {
+	if err := v.checkSourcePolicy(SourceTypeOci, uri); err != nil {
+		return err
+	}
+
+	ref, err := parseOciReference(uri)
+	if err != nil {
+		return err
+	}
+
+	client, err := v.newOciClient(ctx, ref)
+	if err != nil {
+		return err
+	}
+
+	manifest, err := client.fetchManifest(ref.Reference)
+	if err != nil {
+		return err
+	}
+
+	// If the reference points to an image index, use the first manifest from it
+	if len(manifest.Manifests) > 0 {
+		manifest, err = client.fetchManifest(manifest.Manifests[0].Digest)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(manifest.Layers) == 0 {
+		return fmt.Errorf("OCI artifact '%s' does not have any layers", ref)
+	}
+
+	if err = os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+
+	dstFs, err := fs.FromDir(dst)
+	if err != nil {
+		return err
+	}
+
+	for _, layer := range manifest.Layers {
+		if err = client.pullLayer(layer, dstFs); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ociRegistryScheme returns the scheme of the registry API. Registries on the local host and the registries
+// in 'vendor.oci.insecure_registries' (by host or 'host:port') are accessed using plain HTTP (same as Docker does)
+func (v *Vender) ociRegistryScheme(registry string) string {
+	host := registry
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if host == "localhost" || net.ParseIP(host).IsLoopback() {
+		return "http"
+	}
+	if insecure := v.config.Oci.InsecureRegistries; containsFold(insecure, registry) || containsFold(insecure, host) {
+		return "http"
+	}
+	return "https"
+}
+
+type ociClient struct {
+	v          *Vender
+	ctx        context.Context
+	httpClient *http.Client
+	ref        ociReference
+	baseURL    string
+	// Value of the 'Authorization' header obtained after an authentication challenge
+	authorization string
+}
+
+func (v *Vender) newOciClient(ctx context.Context, ref ociReference) (*ociClient, error) {
+	scheme := v.ociRegistryScheme(ref.Registry)
+
+	httpClient, err := v.newHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ociClient{
+		v:          v,
+		ctx:        ctx,
+		httpClient: httpClient,
+		ref:        ref,
+		baseURL:    fmt.Sprintf("%s://%s/v2/%s", scheme, ref.Registry, ref.Repository),
+	}, nil
+}
+
+// fetchManifest fetches the manifest by tag or digest and verifies its digest
+func (c *ociClient) fetchManifest(reference string) (ociManifest, error) {
+	var manifest ociManifest
+
+	resp, err := c.get("/manifests/"+reference, ociMediaTypeImageManifest, ociMediaTypeImageIndex, dockerMediaTypeManifest, dockerMediaTypeManifestList)
+	if err != nil {
+		return manifest, err
+	}
+	defer resp.Body.Close()
+
+	// One byte more than the limit is read to detect the larger manifests
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, ociMaxManifestSize+1))
+	if err != nil {
+		return manifest, err
+	}
+	if int64(len(body)) > ociMaxManifestSize {
+		return manifest, fmt.Errorf("the manifest of '%s' is larger than %d bytes", c.ref, ociMaxManifestSize)
+	}
+
+	// When pulling by digest, the manifest must match the digest from the reference.
+	// Otherwise, it must match the digest reported by the registry (if any)
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if strings.Contains(reference, ":") {
+		digest = reference
+	}
+	if digest != "" {
+		if err = verifyDigest(digest, body); err != nil {
+			return manifest, fmt.Errorf("error verifying the manifest of '%s': %w", c.ref, err)
+		}
+	} else {
+		c.v.logger.Warnw("The registry did not return the digest of the manifest, it's not verified. Use the digest reference (e.g. '@sha256:...') to pin the artifact",
+			"reference", c.ref.String())
+	}
+
+	if err = json.Unmarshal(body, &manifest); err != nil {
+		return manifest, fmt.Errorf("error parsing the manifest of '%s': %w", c.ref, err)
+	}
+
+	return manifest, nil
+}
+
+// pullLayer downloads the layer blob, verifies its size and digest, and unpacks it into the 'dst' folder
+func (c *ociClient) pullLayer(layer ociDescriptor, dst fs.FileSystem) error {
+	l := c.v.logger.With("reference", c.ref.String(), "digest", layer.Digest, "mediaType", layer.MediaType)
+
+	h, encoded, err := newDigester(layer.Digest)
+	if err != nil {
+		return err
+	}
+
+	if maxSize := c.v.config.Policy.MaxArchiveSize; maxSize > 0 && layer.Size > maxSize {
+		return policyError(c.ref.String(), "layer '%s' is larger than %d bytes ('vendor.policy.max_archive_size')", layer.Digest, maxSize)
+	}
+
+	resp, err := c.get("/blobs/" + layer.Digest)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Store the blob in a temp file, so nothing is unpacked before the digest is verified
+	blob, err := ioutil.TempFile("", "homectl-oci-blob-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = blob.Close()
+		_ = os.Remove(blob.Name())
+	}()
+
+	size, err := io.Copy(io.MultiWriter(blob, h), c.v.limitReader(c.ref.String(), resp.Body))
+	if err != nil {
+		return err
+	}
+
+	if layer.Size > 0 && size != layer.Size {
+		return fmt.Errorf("size mismatch for layer '%s' of '%s': expected %d bytes, got %d", layer.Digest, c.ref, layer.Size, size)
+	}
+
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != encoded {
+		return fmt.Errorf("digest mismatch for layer '%s' of '%s': got '%s'", layer.Digest, c.ref, actual)
+	}
+
+	if _, err = blob.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	l.Debug("Unpacking the layer")
+
+	mediaType := layer.MediaType
+	switch {
+	case strings.HasSuffix(mediaType, "tar+gzip") || strings.HasSuffix(mediaType, "tar.gzip"):
+		return extractTarGz(l, blob, dst, 0)
+	case strings.HasSuffix(mediaType, ".tar"):
+		return extractTar(l, blob, dst, 0)
+	}
+
+	// Layers that are not archives are written as files named after the 'org.opencontainers.image.title' annotation
+	title := layer.Annotations[ociAnnotationTitle]
+	if title == "" {
+		return fmt.Errorf("layer '%s' of '%s' has unsupported media type '%s' and no '%s' annotation", layer.Digest, c.ref, mediaType, ociAnnotationTitle)
+	}
+
+	if _, err = dst.SecurePath(title); err != nil {
+		return fmt.Errorf("invalid layer title '%s' of '%s': %w", title, c.ref, err)
+	}
+
+	return dst.WriteFileFromReader(title, blob, 0644)
+}
+
+// get sends a GET request to the registry. If the registry responds with an authentication challenge, the client
+// authenticates and retries the request
+func (c *ociClient) get(path string, accept ...string) (*http.Response, error) {
+	resp, err := c.do(path, accept)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized && c.authorization == "" {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+
+		if err = c.authenticate(challenge); err != nil {
+			return nil, err
+		}
+
+		resp, err = c.do(path, accept)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("error pulling '%s' from the OCI registry: GET %s%s returned %s", c.ref, c.baseURL, path, resp.Status)
+	}
+
+	return resp, nil
+}
+
+func (c *ociClient) do(path string, accept []string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(accept) > 0 {
+		req.Header.Set("Accept", strings.Join(accept, ", "))
+	}
+	if c.authorization != "" {
+		req.Header.Set("Authorization", c.authorization)
+	}
+
+	return c.httpClient.Do(req)
+}
+
+// authenticate handles the 'Basic' and 'Bearer' authentication challenges.
+// Credentials are read from the Docker config file if the registry is listed there
+// https://docs.docker.com/registry/spec/auth/token/
+func (c *ociClient) authenticate(challenge string) error {
+	scheme, params := parseAuthChallenge(challenge)
+	credentials := ociCredentials(c.ref.Registry)
+
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if credentials == "" {
+			return fmt.Errorf("OCI registry '%s' requires credentials. Use 'docker login %s'", c.ref.Registry, c.ref.Registry)
+		}
+		c.authorization = "Basic " + credentials
+		return nil
+	case "bearer":
+	default:
+		return fmt.Errorf("OCI registry '%s' returned unsupported authentication challenge '%s'", c.ref.Registry, challenge)
+	}
+
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return fmt.Errorf("OCI registry '%s' returned invalid authentication realm '%s'", c.ref.Registry, params["realm"])
+	}
+
+	query := realm.Query()
+	if params["service"] != "" {
+		query.Set("service", params["service"])
+	}
+	scope := params["scope"]
+	if scope == "" {
+		scope = fmt.Sprintf("repository:%s:pull", c.ref.Repository)
+	}
+	query.Set("scope", scope)
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return err
+	}
+	if credentials != "" {
+		req.Header.Set("Authorization", "Basic "+credentials)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error authenticating to the OCI registry '%s': %s", c.ref.Registry, resp.Status)
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return err
+	}
+
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	if token.Token == "" {
+		return errors.New("OCI registry authentication did not return a token")
+	}
+
+	c.authorization = "Bearer " + token.Token
+	return nil
+}
+
+// parseAuthChallenge parses the 'WWW-Authenticate' header, e.g. 'Bearer realm="https://auth.docker.io/token",service="registry.docker.io"'
+func parseAuthChallenge(challenge string) (string, map[string]string) {
+	params := map[string]string{}
+
+	parts := strings.SplitN(strings.TrimSpace(challenge), " ", 2)
+	if len(parts) < 2 {
+		return parts[0], params
+	}
+
+	rest := parts[1]
+	for rest != "" {
+		eq := strings.Index(rest, "=")
+		if eq < 0 {
+			break
+		}
+		key := strings.TrimSpace(rest[:eq])
+		rest = rest[eq+1:]
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else if comma := strings.Index(rest, ","); comma >= 0 {
+			value, rest = rest[:comma], rest[comma:]
+		} else {
+			value, rest = rest, ""
+		}
+
+		params[strings.ToLower(key)] = value
+		rest = strings.TrimLeft(rest, ", ")
+	}
+
+	return parts[0], params
+}
+
+// ociCredentials returns the base64-encoded 'user:password' for the registry from the Docker config file
+func ociCredentials(registry string) string {
+	configDir := os.Getenv("DOCKER_CONFIG")
+	if configDir == "" {
+		hd, err := homedir.Dir()
+		if err != nil {
+			return ""
+		}
+		configDir = filepath.Join(hd, ".docker")
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(configDir, "config.json"))
+	if err != nil {
+		return ""
+	}
+
+	var dockerConfig struct {
+		Auths map[string]struct {
+			Auth string `json:"auth"`
+		} `json:"auths"`
+	}
+	if err = json.Unmarshal(content, &dockerConfig); err != nil {
+		return ""
+	}
+
+	for _, key := range []string{registry, "https://" + registry, "http://" + registry} {
+		if auth, ok := dockerConfig.Auths[key]; ok && auth.Auth != "" {
+			if _, err := base64.StdEncoding.DecodeString(auth.Auth); err == nil {
+				return auth.Auth
+			}
+		}
+	}
+
+	return ""
+}
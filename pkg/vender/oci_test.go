@@ -0,0 +1,206 @@
+package vender_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/home-sol/homectl/internal/testutil"
+	"github.com/home-sol/homectl/pkg/config"
+	"github.com/home-sol/homectl/pkg/fs"
+)
+
+// testOciRegistry is an in-process registry serving a single artifact
+type testOciRegistry struct {
+	*httptest.Server
+	manifestDigest string
+	blobs          map[string][]byte
+}
+
+func sha256Digest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func newTestOciRegistry(t *testing.T, repository string, tag string, layer []byte) *testOciRegistry {
+	layerDigest := sha256Digest(layer)
+
+	manifest, err := json.Marshal(map[string]interface{}{
+		"schemaVersion": 2,
+		"mediaType":     "application/vnd.oci.image.manifest.v1+json",
+		"config": map[string]interface{}{
+			"mediaType": "application/vnd.oci.empty.v1+json",
+			"digest":    sha256Digest([]byte("{}")),
+			"size":      2,
+		},
+		"layers": []map[string]interface{}{
+			{
+				"mediaType": "application/vnd.oci.image.layer.v1.tar+gzip",
+				"digest":    layerDigest,
+				"size":      len(layer),
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	r := &testOciRegistry{
+		manifestDigest: sha256Digest(manifest),
+		blobs:          map[string][]byte{layerDigest: layer},
+	}
+
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		prefix := fmt.Sprintf("/v2/%s/", repository)
+		if !strings.HasPrefix(req.URL.Path, prefix) {
+			http.NotFound(w, req)
+			return
+		}
+
+		resource := strings.TrimPrefix(req.URL.Path, prefix)
+		switch {
+		case resource == "manifests/"+tag || strings.HasPrefix(resource, "manifests/sha256:"):
+			// Manifests requested by any digest are served, so the client has to verify them
+			w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+			w.Header().Set("Docker-Content-Digest", r.manifestDigest)
+			_, _ = w.Write(manifest)
+		case strings.HasPrefix(resource, "blobs/"):
+			blob, ok := r.blobs[strings.TrimPrefix(resource, "blobs/")]
+			if !ok {
+				http.NotFound(w, req)
+				return
+			}
+			_, _ = w.Write(blob)
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	t.Cleanup(r.Close)
+
+	return r
+}
+
+func (r *testOciRegistry) host() string {
+	return strings.TrimPrefix(r.URL, "http://")
+}
+
+func TestVenderComponentPullOciSource(t *testing.T) {
+	layer := testutil.TarGz(t, map[string]string{
+		"main.tf":      "resource \"null_resource\" \"this\" {}\n",
+		"variables.tf": "variable \"enabled\" {}\n",
+		"README.md":    "# label\n",
+	})
+	registry := newTestOciRegistry(t, "modules/label", "1.0.0", layer)
+
+	tests := []struct {
+		name string
+		uri  string
+	}{
+		{name: "tag", uri: "oci://" + registry.host() + "/modules/label:{{.Version}}"},
+		{name: "digest", uri: "oci://" + registry.host() + "/modules/label@" + registry.manifestDigest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fss, err := fs.FromDir(t.TempDir())
+			require.NoError(t, err)
+
+			componentPath := "components/terraform/label"
+			spec := config.VendorComponentSpec{
+				Source: config.VendorComponentSource{
+					Type:          "oci",
+					Uri:           tt.uri,
+					Version:       "1.0.0",
+					IncludedPaths: []string{"**/*.tf"},
+				},
+			}
+
+			err = newTestVender(fss, config.Vendor{}).ExecuteComponentVendorCommand(spec, "label", componentPath, false, false, "pull")
+			require.NoError(t, err)
+
+			assert.FileExists(t, fss.GetRelativePath(path.Join(componentPath, "main.tf")))
+			assert.FileExists(t, fss.GetRelativePath(path.Join(componentPath, "variables.tf")))
+			assert.NoFileExists(t, fss.GetRelativePath(path.Join(componentPath, "README.md")))
+		})
+	}
+}
+
+func TestVenderComponentPullOciSourceDigestMismatch(t *testing.T) {
+	registry := newTestOciRegistry(t, "modules/label", "1.0.0", testutil.TarGz(t, map[string]string{"main.tf": "# main\n"}))
+
+	fss, err := fs.FromDir(t.TempDir())
+	require.NoError(t, err)
+
+	// Reference a digest the registry's manifest doesn't match
+	spec := config.VendorComponentSpec{
+		Source: config.VendorComponentSource{
+			Type: "oci",
+			Uri:  "oci://" + registry.host() + "/modules/label@" + sha256Digest([]byte("other")),
+		},
+	}
+	err = newTestVender(fss, config.Vendor{}).ExecuteComponentVendorCommand(spec, "label", "label", false, false, "pull")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "digest mismatch")
+
+	// Tamper with the layer blob, keeping its size
+	for digest, blob := range registry.blobs {
+		tampered := append([]byte{}, blob...)
+		tampered[len(tampered)/2] ^= 0xff
+		registry.blobs[digest] = tampered
+	}
+	spec.Source.Uri = "oci://" + registry.host() + "/modules/label:1.0.0"
+	err = newTestVender(fss, config.Vendor{}).ExecuteComponentVendorCommand(spec, "label", "label", false, false, "pull")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "digest mismatch")
+	assert.NoFileExists(t, fss.GetRelativePath("label/main.tf"))
+}
+
+func TestVenderComponentPullOciInsecureRegistries(t *testing.T) {
+	fss, err := fs.FromDir(t.TempDir())
+	require.NoError(t, err)
+
+	spec := config.VendorComponentSpec{
+		Source: config.VendorComponentSource{
+			Type: "oci",
+			Uri:  "oci://registry.example.com:5000/modules/label:1.0.0",
+		},
+	}
+
+	// The insecure registries are accessed using plain 'http', so the policy denies them
+	vendorConfig := config.Vendor{}
+	vendorConfig.Oci.InsecureRegistries = []string{"registry.example.com"}
+	vendorConfig.Policy.DenyHttp = true
+
+	err = newTestVender(fss, vendorConfig).ExecuteComponentVendorCommand(spec, "label", "label", false, false, "pull")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "plain 'http' is not allowed by 'vendor.policy.deny_http'")
+}
+
+func TestVenderComponentPullOciSourceLargeManifest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+		_, _ = w.Write([]byte(`{"schemaVersion": 2, "layers": [], "padding": "` + strings.Repeat("x", 4*1024*1024) + `"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	fss, err := fs.FromDir(t.TempDir())
+	require.NoError(t, err)
+
+	spec := config.VendorComponentSpec{
+		Source: config.VendorComponentSource{
+			Type: "oci",
+			Uri:  "oci://" + strings.TrimPrefix(server.URL, "http://") + "/modules/label:1.0.0",
+		},
+	}
+
+	err = newTestVender(fss, config.Vendor{}).ExecuteComponentVendorCommand(spec, "label", "label", false, false, "pull")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is larger than 4194304 bytes")
+}
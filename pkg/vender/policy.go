@@ -0,0 +1,312 @@
+package vender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/go-getter"
+)
+
+// forcedGetterRegexp finds the forced getter in the go-getter source (e.g. 'git::https://example.com/repo.git')
+var forcedGetterRegexp = regexp.MustCompile(`^([A-Za-z0-9]+)::(.+)$`)
+
+// policyError is returned when the source is not allowed by 'vendor.policy' in 'homectl.yaml'
+func policyError(uri string, format string, args ...interface{}) error {
+	return fmt.Errorf("uri '%s' is not allowed by the vendor policy: %s", uri, fmt.Sprintf(format, args...))
+}
+
+// checkSourcePolicy checks the source against the 'vendor.policy' rules (allowed schemes, hosts and URI prefixes, and plain 'http').
+// It's called before any network access
+func (v *Vender) checkSourcePolicy(sourceType string, uri string) error {
+	policy := v.config.Policy
+
+	forced, u, err := v.sourceURL(sourceType, uri)
+	if err != nil {
+		return err
+	}
+
+	if policy.DenyHttp && u.Scheme == "http" {
+		return policyError(uri, "plain 'http' is not allowed by 'vendor.policy.deny_http'")
+	}
+
+	if len(policy.AllowedSchemes) > 0 {
+		for _, scheme := range []string{forced, u.Scheme} {
+			if scheme != "" && !containsFold(policy.AllowedSchemes, scheme) {
+				return policyError(uri, "scheme '%s' is not in 'vendor.policy.allowed_schemes' (%s)", scheme, strings.Join(policy.AllowedSchemes, ", "))
+			}
+		}
+	}
+
+	// Local files have no host, they are restricted by the 'file' scheme
+	if len(policy.AllowedHosts) > 0 && u.Hostname() != "" {
+		if !matchesHost(policy.AllowedHosts, u.Hostname()) {
+			return policyError(uri, "host '%s' does not match any of 'vendor.policy.allowed_hosts' (%s)", u.Hostname(), strings.Join(policy.AllowedHosts, ", "))
+		}
+	}
+
+	if len(policy.AllowedUriPrefixes) > 0 {
+		allowed := false
+		for _, prefix := range policy.AllowedUriPrefixes {
+			if strings.HasPrefix(uri, prefix) || strings.HasPrefix(u.String(), prefix) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return policyError(uri, "it does not start with any of 'vendor.policy.allowed_uri_prefixes' (%s)", strings.Join(policy.AllowedUriPrefixes, ", "))
+		}
+	}
+
+	return nil
+}
+
+// checkRedirect checks the redirect targets against 'vendor.policy', so the allowed sources can't redirect to the denied ones.
+// It's used as 'CheckRedirect' of the HTTP clients
+func (v *Vender) checkRedirect(req *http.Request, via []*http.Request) error {
+	// The same limit as the default HTTP client
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	return v.checkSourcePolicy(SourceTypeGetter, req.URL.String())
+}
+
+// sourceURL returns the URL the source is downloaded from, and the forced go-getter (e.g. 'git') or 'oci' if any
+func (v *Vender) sourceURL(sourceType string, uri string) (string, *url.URL, error) {
+	switch sourceType {
+	case SourceTypeTerraformRegistry:
+		address, err := parseTerraformModuleAddress(uri, v.config.TerraformRegistry.Host)
+		if err != nil {
+			return "", nil, err
+		}
+		host := address.Host
+		if !strings.Contains(host, "://") {
+			host = "https://" + host
+		}
+		u, err := url.Parse(host)
+		return "", u, err
+
+	case SourceTypeOci, SourceTypeHelm:
+		u, err := url.Parse(uri)
+		if err != nil {
+			return "", nil, err
+		}
+		// Like the forced getters, the 'oci://' sources are checked as 'oci' and as the scheme the registry is accessed with
+		if strings.EqualFold(u.Scheme, "oci") {
+			u.Scheme = v.ociRegistryScheme(u.Host)
+			return "oci", u, nil
+		}
+		return "", u, nil
+	}
+
+	// Shorthand sources (e.g. 'github.com/org/repo') are detected the same way go-getter does it
+	detected, err := getter.Detect(uri, "", getter.Detectors)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var forced string
+	if m := forcedGetterRegexp.FindStringSubmatch(detected); m != nil {
+		forced, detected = m[1], m[2]
+	}
+
+	u, err := url.Parse(detected)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return forced, u, nil
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesHost checks if the host matches any of the patterns (e.g. 'github.com' or '*.example.com')
+func matchesHost(patterns []string, host string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(host)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSourceLimits checks the downloaded source against 'vendor.policy.max_archive_size' and 'vendor.policy.max_file_count'
+func (v *Vender) checkSourceLimits(uri string, dir string) error {
+	policy := v.config.Policy
+	if policy.MaxArchiveSize <= 0 && policy.MaxFileCount <= 0 {
+		return nil
+	}
+
+	var size int64
+	count := 0
+
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		count++
+		size += info.Size()
+
+		if policy.MaxFileCount > 0 && count > policy.MaxFileCount {
+			return policyError(uri, "it has more than %d files ('vendor.policy.max_file_count')", policy.MaxFileCount)
+		}
+		if policy.MaxArchiveSize > 0 && size > policy.MaxArchiveSize {
+			return policyError(uri, "it is larger than %d bytes ('vendor.policy.max_archive_size')", policy.MaxArchiveSize)
+		}
+		return nil
+	})
+
+	return err
+}
+
+// limitReader returns the reader that fails when more than 'vendor.policy.max_archive_size' bytes are read
+func (v *Vender) limitReader(uri string, r io.Reader) io.Reader {
+	maxSize := v.config.Policy.MaxArchiveSize
+	if maxSize <= 0 {
+		return r
+	}
+	return &limitedReader{uri: uri, r: r, maxSize: maxSize, remaining: maxSize}
+}
+
+type limitedReader struct {
+	uri       string
+	r         io.Reader
+	maxSize   int64
+	remaining int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, policyError(l.uri, "it is larger than %d bytes ('vendor.policy.max_archive_size')", l.maxSize)
+	}
+
+	// Read one byte more than the limit to detect that the limit is exceeded
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n, policyError(l.uri, "it is larger than %d bytes ('vendor.policy.max_archive_size')", l.maxSize)
+	}
+
+	return n, err
+}
+
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// limitTransport limits the size of the HTTP response bodies by 'vendor.policy.max_archive_size'
+type limitTransport struct {
+	v    *Vender
+	base http.RoundTripper
+}
+
+func (t *limitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Body = &limitedReadCloser{Reader: t.v.limitReader(req.URL.String(), resp.Body), Closer: resp.Body}
+	return resp, nil
+}
+
+// newGetterClient returns the go-getter client with the HTTP and git downloads using the 'vendor.http' settings
+// and the HTTP downloads limited by 'vendor.policy.max_archive_size'. The 's3' and 'gcs' getters use their own SDK settings
+func (v *Vender) newGetterClient(ctx context.Context, src string, dst string, mode getter.ClientMode) (*getter.Client, error) {
+	transport, err := v.newHTTPTransport()
+	if err != nil {
+		return nil, err
+	}
+
+	getters := map[string]getter.Getter{}
+	for name, g := range getter.Getters {
+		getters[name] = g
+	}
+
+	httpGetter := &getter.HttpGetter{
+		Netrc:  true,
+		Client: &http.Client{Transport: &limitTransport{v: v, base: transport}, CheckRedirect: v.checkRedirect},
+	}
+	getters["http"] = httpGetter
+	getters["https"] = httpGetter
+	getters["git"] = &gitGetter{Getter: getters["git"], v: v, ctx: ctx}
+
+	// go-getter downloads the sources from the 'X-Terraform-Get' header (or the 'terraform-get' meta tag) with a new client,
+	// configured by the options of this one. The same getters are used, but they check the followed sources against the policy
+	followed := map[string]getter.Getter{}
+	for name, g := range getters {
+		followed[name] = &policyGetter{Getter: g, v: v, name: name}
+	}
+	followedGetters := func(c *getter.Client) error {
+		if c.Getters == nil {
+			c.Getters = followed
+		}
+		return nil
+	}
+
+	return &getter.Client{
+		Ctx: ctx,
+		// Define the destination to where the files will be stored. This will create the directory if it doesn't exist
+		Dst: dst,
+		Dir: mode == getter.ClientModeDir,
+		// Source
+		Src:     src,
+		Mode:    mode,
+		Getters: getters,
+		Options: []getter.ClientOption{followedGetters},
+	}, nil
+}
+
+// policyGetter checks the source against 'vendor.policy' before downloading it with the wrapped getter
+type policyGetter struct {
+	getter.Getter
+	v    *Vender
+	name string
+}
+
+func (g *policyGetter) Get(dst string, u *url.URL) error {
+	if err := g.checkSourcePolicy(u); err != nil {
+		return err
+	}
+	return g.Getter.Get(dst, u)
+}
+
+func (g *policyGetter) GetFile(dst string, u *url.URL) error {
+	if err := g.checkSourcePolicy(u); err != nil {
+		return err
+	}
+	return g.Getter.GetFile(dst, u)
+}
+
+func (g *policyGetter) checkSourcePolicy(u *url.URL) error {
+	uri := u.String()
+	// The getter is forced, unless it's the one for the URL scheme (e.g. 'git::https://example.com/repo.git')
+	if !strings.EqualFold(u.Scheme, g.name) {
+		uri = g.name + "::" + uri
+	}
+	return g.v.checkSourcePolicy(SourceTypeGetter, uri)
+}
@@ -0,0 +1,214 @@
+package vender_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/home-sol/homectl/internal/testutil"
+	"github.com/home-sol/homectl/pkg/config"
+	"github.com/home-sol/homectl/pkg/fs"
+)
+
+func TestVenderComponentPullPolicy(t *testing.T) {
+	archive := testutil.TarGz(t, map[string]string{
+		"main.tf":      "# main\n",
+		"variables.tf": "# variables\n",
+	})
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		_, _ = w.Write(archive)
+	}))
+	t.Cleanup(server.Close)
+
+	vendorConfig := config.Vendor{}
+
+	// The registries on the local host are accessed using plain 'http'
+	ociURI := "oci://" + strings.TrimPrefix(server.URL, "http://") + "/modules/vpc:0.1.0"
+
+	spec := config.VendorComponentSpec{
+		Source: config.VendorComponentSource{
+			Uri: server.URL + "/source.tar.gz",
+		},
+		Mixins: []config.VendorComponentMixins{
+			{Uri: server.URL + "/mixin.tf", Filename: "mixin.tf"},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		policy config.VendorPolicy
+		spec   func(spec config.VendorComponentSpec) config.VendorComponentSpec
+		err    string
+	}{
+		{
+			name:   "plain http",
+			policy: config.VendorPolicy{DenyHttp: true},
+			err:    "plain 'http' is not allowed by 'vendor.policy.deny_http'",
+		},
+		{
+			name:   "scheme",
+			policy: config.VendorPolicy{AllowedSchemes: []string{"https", "git"}},
+			err:    "scheme 'http' is not in 'vendor.policy.allowed_schemes' (https, git)",
+		},
+		{
+			name:   "forced getter",
+			policy: config.VendorPolicy{AllowedSchemes: []string{"http"}},
+			spec: func(spec config.VendorComponentSpec) config.VendorComponentSpec {
+				spec.Source.Uri = "s3::" + spec.Source.Uri
+				return spec
+			},
+			err: "scheme 's3' is not in 'vendor.policy.allowed_schemes' (http)",
+		},
+		{
+			name:   "oci on the local host",
+			policy: config.VendorPolicy{DenyHttp: true},
+			spec: func(spec config.VendorComponentSpec) config.VendorComponentSpec {
+				spec.Source.Type = "oci"
+				spec.Source.Uri = ociURI
+				return spec
+			},
+			err: "uri '" + ociURI + "' is not allowed by the vendor policy: plain 'http' is not allowed by 'vendor.policy.deny_http'",
+		},
+		{
+			name:   "oci scheme",
+			policy: config.VendorPolicy{AllowedSchemes: []string{"https", "oci"}},
+			spec: func(spec config.VendorComponentSpec) config.VendorComponentSpec {
+				spec.Source.Type = "oci"
+				spec.Source.Uri = ociURI
+				return spec
+			},
+			err: "uri '" + ociURI + "' is not allowed by the vendor policy: scheme 'http' is not in 'vendor.policy.allowed_schemes' (https, oci)",
+		},
+		{
+			name:   "host",
+			policy: config.VendorPolicy{AllowedHosts: []string{"github.com", "*.example.com"}},
+			err:    "host '127.0.0.1' does not match any of 'vendor.policy.allowed_hosts' (github.com, *.example.com)",
+		},
+		{
+			name:   "uri prefix",
+			policy: config.VendorPolicy{AllowedUriPrefixes: []string{"github.com/cloudposse/"}},
+			err:    "it does not start with any of 'vendor.policy.allowed_uri_prefixes' (github.com/cloudposse/)",
+		},
+		{
+			name:   "mixin",
+			policy: config.VendorPolicy{AllowedUriPrefixes: []string{server.URL + "/source.tar.gz"}},
+			err:    "uri '" + server.URL + "/mixin.tf' is not allowed by the vendor policy",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vendorConfig.Policy = tt.policy
+			atomic.StoreInt32(&requests, 0)
+
+			s := spec
+			if tt.spec != nil {
+				s = tt.spec(s)
+			}
+
+			fss, err := fs.FromDir(t.TempDir())
+			require.NoError(t, err)
+
+			err = newTestVender(fss, vendorConfig).ExecuteComponentVendorCommand(s, "test", "test", false, false, "pull")
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+
+			// The policy is checked before any network access
+			assert.Equal(t, int32(0), atomic.LoadInt32(&requests))
+		})
+	}
+
+	t.Run("limits", func(t *testing.T) {
+		fss, err := fs.FromDir(t.TempDir())
+		require.NoError(t, err)
+
+		vendorConfig.Policy = config.VendorPolicy{MaxFileCount: 1}
+		err = newTestVender(fss, vendorConfig).ExecuteComponentVendorCommand(spec, "test", "test", false, false, "pull")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "it has more than 1 files ('vendor.policy.max_file_count')")
+
+		vendorConfig.Policy = config.VendorPolicy{MaxArchiveSize: 16}
+		err = newTestVender(fss, vendorConfig).ExecuteComponentVendorCommand(spec, "test", "test", false, false, "pull")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "is larger than 16 bytes ('vendor.policy.max_archive_size')")
+	})
+
+	t.Run("allowed", func(t *testing.T) {
+		fss, err := fs.FromDir(t.TempDir())
+		require.NoError(t, err)
+
+		vendorConfig.Policy = config.VendorPolicy{
+			AllowedSchemes:     []string{"http"},
+			AllowedHosts:       []string{"127.0.0.1"},
+			AllowedUriPrefixes: []string{server.URL + "/"},
+			MaxArchiveSize:     1 << 20,
+			MaxFileCount:       10,
+		}
+		require.NoError(t, newTestVender(fss, vendorConfig).ExecuteComponentVendorCommand(spec, "test", "test", false, false, "pull"))
+		assert.FileExists(t, fss.GetRelativePath("test/main.tf"))
+		assert.FileExists(t, fss.GetRelativePath("test/mixin.tf"))
+	})
+}
+
+func TestVenderComponentPullPolicyRedirects(t *testing.T) {
+	archive := testutil.TarGz(t, map[string]string{"main.tf": "# main\n"})
+
+	// The same server is denied by the host when accessed as 'localhost'
+	var denied int32
+	deniedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&denied, 1)
+		_, _ = w.Write(archive)
+	}))
+	t.Cleanup(deniedServer.Close)
+	deniedURL := strings.Replace(deniedServer.URL, "127.0.0.1", "localhost", 1) + "/source.tar.gz"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/redirect.tar.gz":
+			http.Redirect(w, r, deniedURL, http.StatusFound)
+		case "/module":
+			w.Header().Set("X-Terraform-Get", deniedURL)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			_, _ = w.Write(archive)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	vendorConfig := config.Vendor{
+		Policy: config.VendorPolicy{AllowedHosts: []string{"127.0.0.1"}},
+	}
+
+	for _, uri := range []string{server.URL + "/redirect.tar.gz", server.URL + "/module"} {
+		t.Run(uri, func(t *testing.T) {
+			atomic.StoreInt32(&denied, 0)
+
+			fss, err := fs.FromDir(t.TempDir())
+			require.NoError(t, err)
+
+			spec := config.VendorComponentSpec{Source: config.VendorComponentSource{Uri: uri}}
+			err = newTestVender(fss, vendorConfig).ExecuteComponentVendorCommand(spec, "test", "test", false, false, "pull")
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "host 'localhost' does not match any of 'vendor.policy.allowed_hosts' (127.0.0.1)")
+			assert.Equal(t, int32(0), atomic.LoadInt32(&denied))
+		})
+	}
+
+	t.Run("allowed", func(t *testing.T) {
+		fss, err := fs.FromDir(t.TempDir())
+		require.NoError(t, err)
+
+		vendorConfig.Policy.AllowedHosts = []string{"127.0.0.1", "localhost"}
+		spec := config.VendorComponentSpec{Source: config.VendorComponentSource{Uri: server.URL + "/module"}}
+		require.NoError(t, newTestVender(fss, vendorConfig).ExecuteComponentVendorCommand(spec, "test", "test", false, false, "pull"))
+		assert.FileExists(t, fss.GetRelativePath("test/main.tf"))
+	})
+}
@@ -0,0 +1,167 @@
+package vender
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/home-sol/homectl/pkg/diff"
+	"github.com/home-sol/homectl/pkg/fs"
+)
+
+// secretRule is a pattern of a known secret format
+type secretRule struct {
+	name  string
+	regex *regexp.Regexp
+	// Minimum Shannon entropy (bits per character) of the first submatch, or 0 to report all matches
+	minEntropy float64
+}
+
+var secretRules = []secretRule{
+	{
+		name:  "aws-access-key-id",
+		regex: regexp.MustCompile(`\b(?:AKIA|ASIA|AGPA|AIDA|AROA|AIPA|ANPA|ANVA)[0-9A-Z]{16}\b`),
+	},
+	{
+		name:  "aws-secret-access-key",
+		regex: regexp.MustCompile(`(?i)aws_?secret_?access_?key["']?\s*[:=]\s*["']?[A-Za-z0-9/+=]{40}\b`),
+	},
+	{
+		name:  "private-key",
+		regex: regexp.MustCompile(`-----BEGIN (?:RSA |DSA |EC |OPENSSH |PGP |ENCRYPTED )?PRIVATE KEY(?: BLOCK)?-----`),
+	},
+	{
+		name:  "github-token",
+		regex: regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`),
+	},
+	{
+		name:  "slack-token",
+		regex: regexp.MustCompile(`\bxox[abprs]-[0-9A-Za-z-]{10,}\b`),
+	},
+	{
+		// Quoted random strings (e.g. API tokens). Hex strings (e.g. digests) have lower entropy and are not reported
+		name:       "high-entropy-string",
+		regex:      regexp.MustCompile(`["']([A-Za-z0-9+/=_\-]{32,})["']`),
+		minEntropy: 4.5,
+	},
+}
+
+// SecretFinding is a possible secret found in a vendored file
+type SecretFinding struct {
+	// Path of the file relative to the component folder
+	Path string
+	Line int
+	Rule string
+}
+
+func (f SecretFinding) String() string {
+	return fmt.Sprintf("%s:%d: %s", f.Path, f.Line, f.Rule)
+}
+
+// scanSecrets scans the staged files for known secret patterns, skipping the binary files, the symlinks
+// and the files matching 'vendor.secret_scan.allowed_paths'
+func (v *Vender) scanSecrets(staging fs.FileSystem) ([]SecretFinding, error) {
+	var allowed []pathRule
+	for _, pattern := range v.config.SecretScan.AllowedPaths {
+		rule, err := newPathRule("secret_scan.allowed_paths", pattern)
+		if err != nil {
+			return nil, err
+		}
+		allowed = append(allowed, rule)
+	}
+
+	files, err := listFiles(staging, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []SecretFinding
+
+	for _, file := range files {
+		file = filepath.ToSlash(file)
+
+		if rule := lastMatch(allowed, file); rule != nil && !rule.negate {
+			continue
+		}
+
+		// The symlinks point to the staged files, which are scanned themselves
+		info, err := staging.Lstat(file)
+		if err != nil {
+			return nil, err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			continue
+		}
+
+		content, err := staging.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		if diff.IsBinary(content) {
+			continue
+		}
+
+		findings = append(findings, scanContent(file, content)...)
+	}
+
+	return findings, nil
+}
+
+func scanContent(file string, content []byte) []SecretFinding {
+	var findings []SecretFinding
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), len(content)+1)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+
+		for _, rule := range secretRules {
+			for _, match := range rule.regex.FindAllStringSubmatch(text, -1) {
+				if rule.minEntropy > 0 && entropy(match[1]) < rule.minEntropy {
+					continue
+				}
+				findings = append(findings, SecretFinding{Path: file, Line: line, Rule: rule.name})
+				break
+			}
+		}
+	}
+
+	return findings
+}
+
+// entropy returns the Shannon entropy of the string in bits per character
+func entropy(s string) float64 {
+	counts := map[rune]int{}
+	for _, r := range s {
+		counts[r]++
+	}
+
+	var result float64
+	n := float64(len(s))
+	for _, count := range counts {
+		p := float64(count) / n
+		result -= p * math.Log2(p)
+	}
+
+	return result
+}
+
+// secretsError returns the error listing the secret findings
+func secretsError(findings []SecretFinding) error {
+	lines := make([]string, len(findings))
+	for i, finding := range findings {
+		lines[i] = finding.String()
+	}
+
+	return fmt.Errorf(
+		"possible secrets found in the vendored files:\n  %s\nadd the files to 'vendor.secret_scan.allowed_paths' in 'homectl.yaml', or use '--allow-secrets' to vendor them anyway",
+		strings.Join(lines, "\n  "),
+	)
+}
@@ -0,0 +1,69 @@
+package vender
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/go-getter"
+
+	"github.com/home-sol/homectl/pkg/config"
+)
+
+const (
+	// SourceTypeGetter downloads the source using go-getter. It's used when 'source.type' is not specified
+	SourceTypeGetter = ""
+	// SourceTypeOci pulls the source from an OCI registry
+	SourceTypeOci = "oci"
+	// SourceTypeHelm downloads a chart from a Helm chart repository
+	SourceTypeHelm = "helm"
+	// SourceTypeTerraformRegistry resolves a module using the Terraform module registry protocol
+	SourceTypeTerraformRegistry = "terraform-registry"
+)
+
+// checkSourceType checks if the 'type' of the component source is supported
+func checkSourceType(source config.VendorComponentSource) error {
+	switch source.Type {
+	case SourceTypeGetter, SourceTypeOci, SourceTypeHelm, SourceTypeTerraformRegistry:
+		return nil
+	default:
+		return fmt.Errorf("source type '%s' is not supported. Valid types are 'oci', 'helm' and 'terraform-registry', or no type to use go-getter",
+			source.Type,
+		)
+	}
+}
+
+// downloadSource downloads the component source from 'uri' into the 'dst' folder using the method selected by the source 'type'
+func (v *Vender) downloadSource(ctx context.Context, source config.VendorComponentSource, uri string, dst string) error {
+	switch source.Type {
+	case SourceTypeOci:
+		return v.downloadOciSource(ctx, uri, dst)
+	case SourceTypeHelm:
+		return v.downloadHelmSource(ctx, source, uri, dst)
+	case SourceTypeTerraformRegistry:
+		return v.downloadTerraformRegistrySource(ctx, source, uri, dst)
+	default:
+		return v.downloadGetterSource(ctx, uri, dst)
+	}
+}
+
+// downloadGetterSource downloads the source into the 'dst' folder using go-getter
+// Git sources are downloaded using the shared git cache (see 'downloadGitSource')
+func (v *Vender) downloadGetterSource(ctx context.Context, uri string, dst string) error {
+	if err := v.checkSourcePolicy(SourceTypeGetter, uri); err != nil {
+		return err
+	}
+
+	if src, err := getter.Detect(uri, "", getter.Detectors); err == nil && strings.HasPrefix(src, "git::") {
+		if ok, err := v.downloadGitSource(ctx, src, dst); ok {
+			return err
+		}
+	}
+
+	client, err := v.newGetterClient(ctx, uri, dst, getter.ClientModeDir)
+	if err != nil {
+		return err
+	}
+
+	return client.Get()
+}
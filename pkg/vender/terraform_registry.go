@@ -0,0 +1,412 @@
+package vender
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/go-getter"
+	"github.com/hashicorp/go-version"
+	"github.com/mitchellh/go-homedir"
+
+	"github.com/home-sol/homectl/pkg/config"
+)
+
+// terraformRegistryAddressPattern matches '[<host>/]<namespace>/<name>/<provider>' module registry addresses
+var terraformRegistryAddressPattern = regexp.MustCompile(
+	`^(?:([^/]+)/)?[0-9A-Za-z](?:[0-9A-Za-z_-]{0,62}[0-9A-Za-z])?/[0-9A-Za-z](?:[0-9A-Za-z_-]{0,62}[0-9A-Za-z])?/[0-9a-z]{1,64}$`,
+)
+
+// terraformModuleAddress is a parsed '[<host>/]<namespace>/<name>/<provider>[//<subdir>]' module registry address
+type terraformModuleAddress struct {
+	Host      string
+	Namespace string
+	Name      string
+	Provider  string
+	// The folder in the module package, e.g. 'modules/x' in 'namespace/name/provider//modules/x'
+	Subdir string
+}
+
+func (a terraformModuleAddress) String() string {
+	address := fmt.Sprintf("%s/%s/%s/%s", a.Host, a.Namespace, a.Name, a.Provider)
+	if a.Subdir != "" {
+		address += "//" + a.Subdir
+	}
+	return address
+}
+
+// isTerraformRegistryAddress checks if the Terraform module source is a module registry address (e.g. 'cloudposse/label/null')
+// https://www.terraform.io/language/modules/sources#module-registry
+func isTerraformRegistryAddress(source string) bool {
+	address, _ := getter.SourceDirSubdir(source)
+	if strings.Contains(address, "::") || strings.Contains(address, "://") {
+		return false
+	}
+
+	match := terraformRegistryAddressPattern.FindStringSubmatch(address)
+	if match == nil {
+		return false
+	}
+
+	// Addresses like 'github.com/org/repo/module' are go-getter shorthands
+	host := match[1]
+	if host == "" {
+		return true
+	}
+	if host == "github.com" || host == "bitbucket.org" {
+		return false
+	}
+	return strings.Contains(host, ".") || strings.HasPrefix(host, "localhost")
+}
+
+// parseTerraformModuleAddress parses the module address. If the address does not have a host, 'defaultHost' is used
+func parseTerraformModuleAddress(uri string, defaultHost string) (terraformModuleAddress, error) {
+	var address terraformModuleAddress
+
+	// The '//<subdir>' is split the same way as in 'isTerraformRegistryAddress'
+	module, subDir := getter.SourceDirSubdir(uri)
+	if subDir != "" {
+		subDir = path.Clean(strings.Trim(subDir, "/"))
+		if subDir == ".." || strings.HasPrefix(subDir, "../") {
+			return address, fmt.Errorf("invalid Terraform module registry address '%s', the subdir must be inside the module", uri)
+		}
+		if subDir != "." {
+			address.Subdir = subDir
+		}
+	}
+
+	parts := strings.Split(strings.Trim(module, "/"), "/")
+	switch len(parts) {
+	case 3:
+		address.Host = defaultHost
+	case 4:
+		address.Host = parts[0]
+		parts = parts[1:]
+	default:
+		return address, fmt.Errorf("invalid Terraform module registry address '%s'. Expected '[<host>/]<namespace>/<name>/<provider>[//<subdir>]'", uri)
+	}
+
+	for _, part := range parts {
+		if part == "" {
+			return address, fmt.Errorf("invalid Terraform module registry address '%s'", uri)
+		}
+	}
+
+	address.Namespace, address.Name, address.Provider = parts[0], parts[1], parts[2]
+	return address, nil
+}
+
+// downloadTerraformRegistrySource resolves the module version and the download URL using the Terraform module registry
+// protocol, and downloads the module into the 'dst' folder using go-getter
+// https://www.terraform.io/internals/module-registry-protocol
+func (v *Vender) downloadTerraformRegistrySource(ctx context.Context, source config.VendorComponentSource, uri string, dst string) error {
+	downloadURL, _, err := v.resolveTerraformRegistrySource(ctx, source, uri)
+	if err != nil {
+		return err
+	}
+
+	return v.downloadGetterSource(ctx, downloadURL, dst)
+}
+
+// resolveTerraformRegistrySource returns the go-getter source the module is downloaded from, and its resolved version
+func (v *Vender) resolveTerraformRegistrySource(ctx context.Context, source config.VendorComponentSource, uri string) (string, string, error) {
+	registryConfig := v.config.TerraformRegistry
+
+	if err := v.checkSourcePolicy(SourceTypeTerraformRegistry, uri); err != nil {
+		return "", "", err
+	}
+
+	address, err := parseTerraformModuleAddress(uri, registryConfig.Host)
+	if err != nil {
+		return "", "", err
+	}
+
+	client := &terraformRegistryClient{
+		v:     v,
+		ctx:   ctx,
+		host:  address.Host,
+		token: terraformRegistryToken(address.Host, registryConfig),
+	}
+
+	modulesURL, err := client.discoverModulesURL()
+	if err != nil {
+		return "", "", err
+	}
+
+	moduleURL, err := modulesURL.Parse(fmt.Sprintf("%s/%s/%s/", address.Namespace, address.Name, address.Provider))
+	if err != nil {
+		return "", "", err
+	}
+
+	resolvedVersion, err := client.resolveVersion(moduleURL, source.Version)
+	if err != nil {
+		return "", "", fmt.Errorf("error resolving the version of the module '%s': %w", address, err)
+	}
+
+	downloadURL, err := client.downloadURL(moduleURL, resolvedVersion)
+	if err != nil {
+		return "", "", fmt.Errorf("error getting the download URL of the module '%s' version '%s': %w", address, resolvedVersion, err)
+	}
+
+	// The subdir of the address is relative to the subdir of the download location, if it has one
+	if address.Subdir != "" {
+		location, locationSubdir := getter.SourceDirSubdir(downloadURL)
+		downloadURL = withSourceSubdir(location, path.Join(locationSubdir, address.Subdir))
+	}
+
+	v.logger.Infow("Resolved Terraform module", "module", address.String(), "version", resolvedVersion, "uri", downloadURL)
+
+	return downloadURL, resolvedVersion, nil
+}
+
+// withSourceSubdir adds the '//<subdir>' to the go-getter source, before the query (e.g. 'https://example.com/module.tar.gz//modules/x?archive=tgz')
+func withSourceSubdir(src string, subDir string) string {
+	query := ""
+	if i := strings.Index(src, "?"); i >= 0 {
+		src, query = src[:i], src[i:]
+	}
+	return src + "//" + subDir + query
+}
+
+type terraformRegistryClient struct {
+	v     *Vender
+	ctx   context.Context
+	host  string
+	token string
+}
+
+// baseURL returns the URL of the registry host. The host can be prefixed with 'http://' or 'https://' (default)
+func (c *terraformRegistryClient) baseURL() (*url.URL, error) {
+	host := c.host
+	if !strings.HasPrefix(host, "http://") && !strings.HasPrefix(host, "https://") {
+		host = "https://" + host
+	}
+	return url.Parse(host)
+}
+
+// discoverModulesURL finds the base URL of the modules API using the service discovery protocol
+// https://www.terraform.io/internals/remote-service-discovery
+func (c *terraformRegistryClient) discoverModulesURL() (*url.URL, error) {
+	base, err := c.baseURL()
+	if err != nil {
+		return nil, err
+	}
+
+	discoveryURL, err := base.Parse("/.well-known/terraform.json")
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.get(discoveryURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var services map[string]interface{}
+	if err = json.NewDecoder(resp.Body).Decode(&services); err != nil {
+		return nil, fmt.Errorf("error parsing the service discovery document of '%s': %w", c.host, err)
+	}
+
+	modules, ok := services["modules.v1"].(string)
+	if !ok || modules == "" {
+		return nil, fmt.Errorf("host '%s' does not provide a Terraform module registry", c.host)
+	}
+
+	if !strings.HasSuffix(modules, "/") {
+		modules += "/"
+	}
+
+	// The modules URL can be relative to the discovery document
+	return discoveryURL.Parse(modules)
+}
+
+// resolveVersion finds the highest module version that satisfies the version constraint
+// If the constraint is not specified, the latest stable version is used
+func (c *terraformRegistryClient) resolveVersion(moduleURL *url.URL, constraint string) (string, error) {
+	versionsURL, err := moduleURL.Parse("versions")
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := c.get(versionsURL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var versions struct {
+		Modules []struct {
+			Versions []struct {
+				Version string `json:"version"`
+			} `json:"versions"`
+		} `json:"modules"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&versions); err != nil {
+		return "", err
+	}
+
+	var constraints version.Constraints
+	if constraint != "" {
+		constraints, err = version.NewConstraint(constraint)
+		if err != nil {
+			return "", fmt.Errorf("invalid version constraint '%s': %w", constraint, err)
+		}
+	}
+
+	var resolved *version.Version
+	for _, module := range versions.Modules {
+		for _, v := range module.Versions {
+			candidate, err := version.NewVersion(v.Version)
+			if err != nil {
+				continue
+			}
+
+			// Pre-release versions are only used when they are pinned exactly
+			if candidate.Prerelease() != "" && constraint != v.Version {
+				continue
+			}
+
+			if constraints != nil && !constraints.Check(candidate) {
+				continue
+			}
+
+			if resolved == nil || candidate.GreaterThan(resolved) {
+				resolved = candidate
+			}
+		}
+	}
+
+	if resolved == nil {
+		if constraint == "" {
+			return "", errors.New("no stable version found")
+		}
+		return "", fmt.Errorf("no version matches '%s'", constraint)
+	}
+
+	return resolved.Original(), nil
+}
+
+// downloadURL returns the go-getter source address of the module version from the 'X-Terraform-Get' header
+func (c *terraformRegistryClient) downloadURL(moduleURL *url.URL, moduleVersion string) (string, error) {
+	downloadURL, err := moduleURL.Parse(url.PathEscape(moduleVersion) + "/download")
+	if err != nil {
+		return "", err
+	}
+
+	// The registry protocol uses '204 No Content', some registries respond with '200 OK'
+	resp, err := c.get(downloadURL, http.StatusNoContent, http.StatusOK)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	location := resp.Header.Get("X-Terraform-Get")
+	if location == "" {
+		return "", errors.New("the registry did not return the 'X-Terraform-Get' header")
+	}
+
+	// The location can be relative to the download endpoint
+	// https://www.terraform.io/internals/module-registry-protocol#download-source-code-for-a-specific-module-version
+	if strings.HasPrefix(location, "/") || strings.HasPrefix(location, "./") || strings.HasPrefix(location, "../") {
+		resolved, err := downloadURL.Parse(location)
+		if err != nil {
+			return "", err
+		}
+		location = resolved.String()
+	}
+
+	return location, nil
+}
+
+// get sends the GET request to the registry API. The response must have one of the statuses ('200 OK' by default)
+func (c *terraformRegistryClient) get(u *url.URL, statuses ...int) (*http.Response, error) {
+	if len(statuses) == 0 {
+		statuses = []int{http.StatusOK}
+	}
+
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	httpClient, err := c.v.newHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, status := range statuses {
+		if resp.StatusCode == status {
+			return resp, nil
+		}
+	}
+
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, fmt.Errorf("GET %s returned %s, check the API token of the registry ('vendor.terraform_registry.token', the 'TF_TOKEN_<host>' ENV var or 'terraform login')", u, resp.Status)
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("GET %s returned %s, the module or its version is not found in the registry", u, resp.Status)
+	}
+
+	return nil, fmt.Errorf("GET %s returned %s", u, resp.Status)
+}
+
+// terraformRegistryToken returns the API token for the registry host.
+// The token is taken from the CLI config (for the configured registry host), from the 'TF_TOKEN_<host>' ENV var,
+// or from the credentials file created by 'terraform login'
+// https://www.terraform.io/cli/config/config-file#environment-variable-credentials
+func terraformRegistryToken(host string, registryConfig config.TerraformRegistry) string {
+	if host == registryConfig.Host && registryConfig.Token != "" {
+		return registryConfig.Token
+	}
+
+	hostname := strings.TrimPrefix(strings.TrimPrefix(host, "https://"), "http://")
+
+	envName := "TF_TOKEN_" + strings.ReplaceAll(strings.ReplaceAll(hostname, "-", "__"), ".", "_")
+	if token := os.Getenv(envName); token != "" {
+		return token
+	}
+
+	hd, err := homedir.Dir()
+	if err != nil {
+		return ""
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(hd, ".terraform.d", "credentials.tfrc.json"))
+	if err != nil {
+		return ""
+	}
+
+	var credentials struct {
+		Credentials map[string]struct {
+			Token string `json:"token"`
+		} `json:"credentials"`
+	}
+	if err = json.Unmarshal(content, &credentials); err != nil {
+		return ""
+	}
+
+	return credentials.Credentials[hostname].Token
+}
@@ -0,0 +1,178 @@
+package vender_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/home-sol/homectl/internal/testutil"
+	"github.com/home-sol/homectl/pkg/config"
+	"github.com/home-sol/homectl/pkg/fs"
+)
+
+// newTestTerraformRegistry serves the module registry protocol for the 'cloudposse/label/null' module
+// The API requires the 'token' and the module archives are served without authentication
+func newTestTerraformRegistry(t *testing.T, token string, versions ...string) *httptest.Server {
+	mux := http.NewServeMux()
+
+	authorized := func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer "+token {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			next(w, r)
+		}
+	}
+
+	mux.HandleFunc("/.well-known/terraform.json", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"modules.v1": "/api/modules/v1/"}`))
+	})
+
+	var list []string
+	for _, v := range versions {
+		list = append(list, fmt.Sprintf(`{"version": "%s"}`, v))
+
+		v := v
+		mux.HandleFunc("/api/modules/v1/cloudposse/label/null/"+v+"/download", authorized(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Terraform-Get", "/archives/label-"+v+".tar.gz")
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		mux.HandleFunc("/archives/label-"+v+".tar.gz", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write(testutil.TarGz(t, map[string]string{
+				"main.tf":           "# version " + v + "\n",
+				"versions.tf":       "terraform {}\n",
+				"modules/x/main.tf": "# module x version " + v + "\n",
+			}))
+		})
+	}
+
+	mux.HandleFunc("/api/modules/v1/cloudposse/label/null/versions", authorized(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `{"modules": [{"versions": [%s]}]}`, strings.Join(list, ","))
+	}))
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestVenderComponentPullTerraformRegistrySource(t *testing.T) {
+	registry := newTestTerraformRegistry(t, "secret", "0.24.1", "0.25.0", "0.26.0-beta.1", "1.0.0")
+
+	vendorConfig := config.Vendor{}
+	vendorConfig.TerraformRegistry = config.TerraformRegistry{Host: registry.URL, Token: "secret"}
+
+	tests := []struct {
+		version  string
+		expected string
+	}{
+		{version: "", expected: "1.0.0"},
+		{version: "~> 0.25.0", expected: "0.25.0"},
+		{version: "< 0.25", expected: "0.24.1"},
+		{version: "0.26.0-beta.1", expected: "0.26.0-beta.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			fss, err := fs.FromDir(t.TempDir())
+			require.NoError(t, err)
+
+			spec := config.VendorComponentSpec{
+				Source: config.VendorComponentSource{
+					Type:    "terraform-registry",
+					Uri:     "cloudposse/label/null",
+					Version: tt.version,
+				},
+			}
+
+			err = newTestVender(fss, vendorConfig).ExecuteComponentVendorCommand(spec, "label", "label", false, false, "pull")
+			require.NoError(t, err)
+
+			main, err := ioutil.ReadFile(fss.GetRelativePath(path.Join("label", "main.tf")))
+			require.NoError(t, err)
+			assert.Equal(t, "# version "+tt.expected+"\n", string(main))
+			assert.FileExists(t, fss.GetRelativePath(path.Join("label", "versions.tf")))
+		})
+	}
+
+	t.Run("subdir", func(t *testing.T) {
+		fss, err := fs.FromDir(t.TempDir())
+		require.NoError(t, err)
+
+		spec := config.VendorComponentSpec{
+			Source: config.VendorComponentSource{Type: "terraform-registry", Uri: "cloudposse/label/null//modules/x", Version: "0.25.0"},
+		}
+		require.NoError(t, newTestVender(fss, vendorConfig).ExecuteComponentVendorCommand(spec, "x", "x", false, false, "pull"))
+
+		main, err := ioutil.ReadFile(fss.GetRelativePath(path.Join("x", "main.tf")))
+		require.NoError(t, err)
+		assert.Equal(t, "# module x version 0.25.0\n", string(main))
+		assert.NoFileExists(t, fss.GetRelativePath(path.Join("x", "versions.tf")))
+
+		spec.Source.Uri = "cloudposse/label/null//../x"
+		err = newTestVender(fss, vendorConfig).ExecuteComponentVendorCommand(spec, "y", "y", false, false, "pull")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "the subdir must be inside the module")
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		vendorConfig.TerraformRegistry.Token = "wrong"
+
+		fss, err := fs.FromDir(t.TempDir())
+		require.NoError(t, err)
+
+		spec := config.VendorComponentSpec{
+			Source: config.VendorComponentSource{Type: "terraform-registry", Uri: "cloudposse/label/null"},
+		}
+		err = newTestVender(fss, vendorConfig).ExecuteComponentVendorCommand(spec, "label", "label", false, false, "pull")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "401")
+		assert.Contains(t, err.Error(), "check the API token of the registry")
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		vendorConfig.TerraformRegistry.Token = "secret"
+
+		fss, err := fs.FromDir(t.TempDir())
+		require.NoError(t, err)
+
+		spec := config.VendorComponentSpec{
+			Source: config.VendorComponentSource{Type: "terraform-registry", Uri: "cloudposse/label/aws"},
+		}
+		err = newTestVender(fss, vendorConfig).ExecuteComponentVendorCommand(spec, "label", "label", false, false, "pull")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "404 Not Found, the module or its version is not found in the registry")
+	})
+}
+
+func TestVenderComponentPullTerraformRegistryNoContent(t *testing.T) {
+	// Only the download endpoint responds with '204 No Content'
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/.well-known/terraform.json" {
+			_, _ = w.Write([]byte(`{"modules.v1": "/api/modules/v1/"}`))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+
+	vendorConfig := config.Vendor{}
+	vendorConfig.TerraformRegistry = config.TerraformRegistry{Host: server.URL}
+
+	fss, err := fs.FromDir(t.TempDir())
+	require.NoError(t, err)
+
+	spec := config.VendorComponentSpec{
+		Source: config.VendorComponentSource{Type: "terraform-registry", Uri: "cloudposse/label/null"},
+	}
+	err = newTestVender(fss, vendorConfig).ExecuteComponentVendorCommand(spec, "label", "label", false, false, "pull")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "/api/modules/v1/cloudposse/label/null/versions returned 204 No Content")
+}
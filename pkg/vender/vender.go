@@ -0,0 +1,31 @@
+package vender
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"github.com/home-sol/homectl/pkg/config"
+	"github.com/home-sol/homectl/pkg/fs"
+)
+
+// Vender vendors the components of a repo using its own 'vendor' config, logger and context,
+// so several repos (or the same repo with different configs) can be vendored in the same process
+type Vender struct {
+	ctx context.Context
+	// The repo file system with 'base_path' as the base folder
+	fs     fs.FileSystem
+	config config.Vendor
+	logger *zap.SugaredLogger
+}
+
+// New returns the vender for the repo file system 'fss' (with 'base_path' as the base folder) using the 'vendor' config.
+// The context cancels the downloads
+func New(ctx context.Context, fss fs.FileSystem, vendorConfig config.Vendor, l *zap.SugaredLogger) *Vender {
+	return &Vender{
+		ctx:    ctx,
+		fs:     fss,
+		config: vendorConfig,
+		logger: l,
+	}
+}
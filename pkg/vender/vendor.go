@@ -2,239 +2,585 @@ package vender
 
 import (
 	"bytes"
-	"context"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
 	"strconv"
-	"strings"
 	"text/template"
 	"time"
 
-	"github.com/bmatcuk/doublestar/v4"
 	"github.com/hashicorp/go-getter"
-	"github.com/otiai10/copy"
+	"go.uber.org/zap"
 
 	"github.com/home-sol/homectl/pkg/config"
+	"github.com/home-sol/homectl/pkg/diff"
 	"github.com/home-sol/homectl/pkg/fs"
-	"github.com/home-sol/homectl/pkg/logger"
-	"github.com/home-sol/homectl/pkg/utils"
 )
 
 // executeComponentVendorCommandInternal executes a component vendor command
 // Supports all protocols (local files, Git, Mercurial, HTTP, HTTPS, Amazon S3, Google GCP),
 // URL and archive formats described in https://github.com/hashicorp/go-getter
+// Sources with 'type: oci' are pulled from OCI registries
 // https://www.allee.xyz/en/posts/getting-started-with-go-getter
 // https://github.com/otiai10/copy
-func ExecuteComponentVendorCommand(
-	fss *fs.FileSystem,
+func (v *Vender) ExecuteComponentVendorCommand(
 	vendorComponentSpec config.VendorComponentSpec,
 	component string,
 	componentPath string,
 	dryRun bool,
+	allowSecrets bool,
 	vendorCommand string,
 ) error {
 
-	l := logger.Logger.With("component", component, "componentPath", componentPath)
+	switch vendorCommand {
+	case "pull":
+		// The component is locked for the whole pull, so the concurrent pulls don't write into the component folder at once
+		if !dryRun {
+			unlock, err := v.lockComponent(v.logger.With("component", component), componentPath)
+			if err != nil {
+				return err
+			}
+			defer unlock()
+		}
+
+		return v.pullComponent(vendorComponentSpec, component, componentPath, dryRun, allowSecrets)
+	case "diff":
+		return v.DiffComponentVendorFiles(vendorComponentSpec, component, componentPath, os.Stdout)
+	}
+
+	return nil
+}
+
+func (v *Vender) pullComponent(
+	vendorComponentSpec config.VendorComponentSpec,
+	component string,
+	componentPath string,
+	dryRun bool,
+	allowSecrets bool,
+) error {
+
+	l := v.logger.With("component", component, "componentPath", componentPath)
+
+	l.Info("Pulling sources for the component")
+
+	if dryRun {
+		// Nothing is staged in the dry run, the empty staging folder is only used to validate the paths
+		return v.stageComponent(l, vendorComponentSpec, componentPath, fs.NewMemFileSystem(), true)
+	}
+
+	// The vendored files are staged and then merged into the component folder with the local modifications
+	staging, err := v.fs.TempDir(strconv.FormatInt(time.Now().Unix(), 10))
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := staging.RemoveAll("."); err != nil {
+			l.Error(err)
+		}
+	}()
+
+	if err = v.stageComponent(l, vendorComponentSpec, componentPath, staging, false); err != nil {
+		return err
+	}
+
+	// The staged files are scanned for secrets before they are written into the component folder
+	if v.config.SecretScan.Enabled {
+		findings, err := v.scanSecrets(staging)
+		if err != nil {
+			return err
+		}
+
+		if len(findings) > 0 {
+			if !allowSecrets {
+				return secretsError(findings)
+			}
+
+			for _, finding := range findings {
+				l.Warnw("Vendoring the file with a possible secret ('--allow-secrets' is specified)", "file", finding.Path, "line", finding.Line, "rule", finding.Rule)
+			}
+		}
+	}
+
+	componentFs, err := v.fs.Sub(componentPath)
+	if err != nil {
+		return err
+	}
+
+	// Three-way merge of the upstream changes with the local modifications, using the files vendored by the last pull as the base
+	actions, err := planMerge(l, staging, componentFs)
+	if err != nil {
+		return err
+	}
+
+	conflicts, err := applyMerge(actions, staging, componentFs)
+	if err != nil {
+		return err
+	}
+
+	if len(conflicts) > 0 {
+		return conflictsError(conflicts)
+	}
+
+	return nil
+}
+
+// DiffComponentVendorFiles writes the unified diff of the changes that 'vendor pull' would make in the component folder,
+// including the 'mappings', 'rewrites', mixins and the merge with the local modifications
+func (v *Vender) DiffComponentVendorFiles(
+	vendorComponentSpec config.VendorComponentSpec,
+	component string,
+	componentPath string,
+	w io.Writer,
+) error {
+
+	l := v.logger.With("component", component, "componentPath", componentPath)
+
+	// The component is locked, so the diff is not made against the files being written by a concurrent pull
+	unlock, err := v.lockComponent(l, componentPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	staging, err := v.fs.TempDir(strconv.FormatInt(time.Now().Unix(), 10))
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := staging.RemoveAll("."); err != nil {
+			l.Error(err)
+		}
+	}()
+
+	if err = v.stageComponent(l, vendorComponentSpec, componentPath, staging, false); err != nil {
+		return err
+	}
+
+	componentFs, err := v.fs.Sub(componentPath)
+	if err != nil {
+		return err
+	}
+
+	actions, err := planMerge(l, staging, componentFs)
+	if err != nil {
+		return err
+	}
+
+	for _, action := range actions {
+		fromName, toName := "a/"+filepath.ToSlash(action.file), "b/"+filepath.ToSlash(action.file)
+		if action.local == nil {
+			fromName = diff.DevNull
+		}
+		if action.remove {
+			toName = diff.DevNull
+		}
+
+		if _, err = io.WriteString(w, diff.Unified(fromName, toName, action.local, action.content, 3)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// stageComponent pulls the source and the mixins of the component into the staging folder.
+// The source and the mixins are downloaded into the OS temp folder, and copied into the staging file system.
+// The files from the source are filtered using 'included_paths' and 'excluded_paths', moved using 'mappings' and
+// changed using 'rewrites' before the mixins are pulled. With 'dryRun', only the validation and logging are done
+func (v *Vender) stageComponent(
+	l *zap.SugaredLogger,
+	vendorComponentSpec config.VendorComponentSpec,
+	componentPath string,
+	staging fs.FileSystem,
+	dryRun bool,
+) error {
 
 	var tempDir string
 	var err error
-	var t *template.Template
 	var uri string
 
-	if vendorCommand == "pull" {
-		if vendorComponentSpec.Source.Uri == "" {
-			return errors.New("'uri' must be specified in 'source.uri' in the 'component.yaml' file")
+	if vendorComponentSpec.Source.Uri == "" {
+		return errors.New("'uri' must be specified in 'source.uri' in the 'component.yaml' file")
+	}
+
+	if err = checkSourceType(vendorComponentSpec.Source); err != nil {
+		return err
+	}
+
+	uri, err = v.sourceUri(vendorComponentSpec.Source)
+	if err != nil {
+		return err
+	}
+
+	// Patterns in 'included_paths', 'excluded_paths', 'mappings' and 'rewrites' are validated before downloading the source
+	matcher, err := newPathMatcher(vendorComponentSpec.Source)
+	if err != nil {
+		return err
+	}
+
+	mappings, err := newPathMappings(vendorComponentSpec.Source)
+	if err != nil {
+		return err
+	}
+
+	rewrites, err := newContentRewrites(vendorComponentSpec.Source)
+	if err != nil {
+		return err
+	}
+
+	filesPolicy, err := newFilePolicy(vendorComponentSpec.Source)
+	if err != nil {
+		return err
+	}
+
+	// The source and the mixins are checked against 'vendor.policy' before any network access
+	if err = v.checkSourcePolicy(vendorComponentSpec.Source.Type, uri); err != nil {
+		return err
+	}
+
+	mixinUris := make([]string, len(vendorComponentSpec.Mixins))
+	for i, mixin := range vendorComponentSpec.Mixins {
+		if mixin.Uri == "" {
+			return errors.New("'uri' must be specified for each 'mixin' in the 'component.yaml' file")
 		}
 
-		// Parse 'uri' template
-		if vendorComponentSpec.Source.Version != "" {
-			t, err = template.New(fmt.Sprintf("source-uri-%s", vendorComponentSpec.Source.Version)).Parse(vendorComponentSpec.Source.Uri)
-			if err != nil {
-				return err
-			}
+		if mixin.Filename == "" {
+			return errors.New("'filename' must be specified for each 'mixin' in the 'component.yaml' file")
+		}
 
-			var tpl bytes.Buffer
-			err = t.Execute(&tpl, vendorComponentSpec.Source)
-			if err != nil {
-				return err
-			}
+		if path.Clean(filepath.ToSlash(mixin.Filename)) == "." {
+			return fmt.Errorf("invalid 'filename' '%s' of the mixin '%s', it must be a file path inside the component folder", mixin.Filename, mixin.Uri)
+		}
+		if _, err = staging.SecurePath(mixin.Filename); err != nil {
+			return fmt.Errorf("invalid 'filename' '%s' of the mixin '%s': %w", mixin.Filename, mixin.Uri, err)
+		}
 
-			uri = tpl.String()
-		} else {
-			uri = vendorComponentSpec.Source.Uri
+		if mixinUris[i], err = v.mixinUri(mixin); err != nil {
+			return err
 		}
 
-		l.Infof("Pulling sources for the component from '%s'", uri)
+		if err = v.checkSourcePolicy(SourceTypeGetter, mixinUris[i]); err != nil {
+			return err
+		}
+	}
 
-		if !dryRun {
-			// Create temp folder
-			// We are using a temp folder for the following reasons:
-			// 1. 'git' does not clone into an existing folder (and we have the existing component folder with `component.yaml` in it)
-			// 2. We have the option to skip some files we don't need and include only the files we need when copying from the temp folder to the destination folder
-			tempDir, err = ioutil.TempDir("", strconv.FormatInt(time.Now().Unix(), 10))
+	l.Infof("Pulling the source '%s'", uri)
+
+	if !dryRun {
+		// Create temp folder
+		// We are using a temp folder for the following reasons:
+		// 1. 'git' does not clone into an existing folder (and we have the existing component folder with `component.yaml` in it)
+		// 2. We have the option to skip some files we don't need and include only the files we need when copying from the temp folder to the destination folder
+		tempDir, err = ioutil.TempDir("", strconv.FormatInt(time.Now().Unix(), 10))
+		if err != nil {
+			return err
+		}
+
+		defer func(path string) {
+			err := os.RemoveAll(path)
 			if err != nil {
-				return err
+				l.Error(err)
 			}
+		}(tempDir)
 
-			defer func(path string) {
-				err := os.RemoveAll(path)
-				if err != nil {
-					l.Error(err)
-				}
-			}(tempDir)
-
-			// Download the source into the temp folder
-			client := &getter.Client{
-				Ctx: context.Background(),
-				// Define the destination to where the files will be stored. This will create the directory if it doesn't exist
-				Dst: tempDir,
-				Dir: true,
-				// Source
-				Src:  uri,
-				Mode: getter.ClientModeDir,
-			}
+		// Download the source into the temp folder
+		if err = v.downloadSource(v.ctx, vendorComponentSpec.Source, uri, tempDir); err != nil {
+			return err
+		}
 
-			if err = client.Get(); err != nil {
-				return err
+		if err = v.checkSourceLimits(uri, tempDir); err != nil {
+			return err
+		}
+
+		// The symlinks are followed before matching, so the files in the linked folders are matched by their new paths
+		if err = filesPolicy.resolveSymlinks(tempDir); err != nil {
+			return err
+		}
+
+		// Decide which files are vendored using the 'included_paths' and 'excluded_paths' rules
+		decisions, err := matchSourceFiles(matcher, tempDir)
+		if err != nil {
+			return err
+		}
+
+		// Copy the included files from the temp folder to the staging folder, moving them using the 'mappings'
+		targets := map[string]string{}
+
+		for _, decision := range decisions {
+			if !decision.Included {
+				l.Infow("Excluding the file", "src", decision.Path, "rule", decision.Reason)
+				continue
 			}
 
-			// Copy from the temp folder to the destination folder with skipping of some files
-			copyOptions := copy.Options{
-				// Skip specifies which files should be skipped
-				Skip: func(src string) (bool, error) {
-					if strings.HasSuffix(src, ".git") {
-						return true, nil
-					}
-
-					trimmedSrc := utils.TrimBasePathFromPath(tempDir+"/", src)
-
-					// Exclude the files that match the 'excluded_paths' patterns
-					// It supports POSIX-style Globs for file names/paths (double-star `**` is supported)
-					// https://en.wikipedia.org/wiki/Glob_(programming)
-					// https://github.com/bmatcuk/doublestar#patterns
-					for _, excludePath := range vendorComponentSpec.Source.ExcludedPaths {
-						excludeMatch, err := doublestar.PathMatch(excludePath, src)
-						if err != nil {
-							return true, err
-						} else if excludeMatch {
-							// If the file matches ANY of the 'excluded_paths' patterns, exclude the file
-							l.Infow("Excluding the file", "src", trimmedSrc, "pattern", excludePath)
-							return true, nil
-						}
-					}
-
-					// Only include the files that match the 'included_paths' patterns (if any pattern is specified)
-					if len(vendorComponentSpec.Source.IncludedPaths) > 0 {
-						for _, includePath := range vendorComponentSpec.Source.IncludedPaths {
-							includeMatch, err := doublestar.PathMatch(includePath, src)
-							if err != nil {
-								return true, err
-							} else if includeMatch {
-								// If the file matches ANY of the 'included_paths' patterns, include the file
-								l.Infow("Including the file", "src", trimmedSrc, "pattern", includePath)
-								return false, nil
-							}
-						}
-
-						l.Infof("Excluding since it does not match any pattern from 'included_paths'", "src", trimmedSrc)
-						return true, nil
-					}
-
-					// If 'included_paths' is not provided, include all files that were not excluded
-					l.Infof("Including the file", "src", trimmedSrc)
-					return false, nil
-				},
-
-				// Preserve the atime and the mtime of the entries
-				// On linux we can preserve only up to 1 millisecond accuracy
-				PreserveTimes: false,
-
-				// Preserve the uid and the gid of all entries
-				PreserveOwner: false,
+			target := mapPath(mappings, decision.Path)
+			l.Infow("Including the file", "src", decision.Path, "dst", target, "rule", decision.Reason)
+
+			if src, ok := targets[target]; ok {
+				return fmt.Errorf("the files '%s' and '%s' are mapped to the same path '%s' in 'mappings'", src, decision.Path, target)
 			}
+			targets[target] = decision.Path
 
-			if err = copy.Copy(tempDir, fss.GetRelativePath(componentPath), copyOptions); err != nil {
+			if err = stageFile(staging, filepath.Join(tempDir, filepath.FromSlash(decision.Path)), target); err != nil {
 				return err
 			}
 		}
 
-		// Process mixins
-		if len(vendorComponentSpec.Mixins) > 0 {
-			for _, mixin := range vendorComponentSpec.Mixins {
-				if mixin.Uri == "" {
-					return errors.New("'uri' must be specified for each 'mixin' in the 'component.yaml' file")
+		// Apply the 'rewrites' to the staged files
+		if len(rewrites) > 0 {
+			for target := range targets {
+				if err = rewriteFile(l, rewrites, staging, target); err != nil {
+					return err
 				}
+			}
+		}
+	}
+
+	// Process mixins
+	if len(vendorComponentSpec.Mixins) > 0 {
+		for i, mixin := range vendorComponentSpec.Mixins {
+			uri = mixinUris[i]
+
+			l.With("componentPath", path.Join(componentPath, mixin.Filename)).Infof("Pulling the mixin '%s'", uri)
 
-				if mixin.Filename == "" {
-					return errors.New("'filename' must be specified for each 'mixin' in the 'component.yaml' file")
+			if !dryRun {
+				err = os.RemoveAll(tempDir)
+				if err != nil {
+					return err
 				}
 
-				// Parse 'uri' template
-				if mixin.Version != "" {
-					t, err = template.New(fmt.Sprintf("mixin-uri-%s", mixin.Version)).Parse(mixin.Uri)
-					if err != nil {
-						return err
-					}
-
-					var tpl bytes.Buffer
-					err = t.Execute(&tpl, mixin)
-					if err != nil {
-						return err
-					}
-
-					uri = tpl.String()
-				} else {
-					uri = mixin.Uri
+				// Download the mixin into the temp file
+				client, err := v.newGetterClient(v.ctx, uri, path.Join(tempDir, mixin.Filename), getter.ClientModeFile)
+				if err != nil {
+					return err
 				}
 
-				l.With("componentPath", path.Join(componentPath, mixin.Filename)).Infof("Pulling the mixin '%s'", uri)
-
-				if !dryRun {
-					err = os.RemoveAll(tempDir)
-					if err != nil {
-						return err
-					}
-
-					// Download the mixin into the temp file
-					client := &getter.Client{
-						Ctx:  context.Background(),
-						Dst:  path.Join(tempDir, mixin.Filename),
-						Dir:  false,
-						Src:  uri,
-						Mode: getter.ClientModeFile,
-					}
-
-					if err = client.Get(); err != nil {
-						return err
-					}
-
-					// Copy from the temp folder to the destination folder
-					copyOptions := copy.Options{
-						// Preserve the atime and the mtime of the entries
-						PreserveTimes: false,
-
-						// Preserve the uid and the gid of all entries
-						PreserveOwner: false,
-					}
-
-					if err = copy.Copy(tempDir, fss.GetRelativePath(componentPath), copyOptions); err != nil {
-						return err
-					}
+				if err = client.Get(); err != nil {
+					return err
 				}
+
+				// Copy from the temp folder to the staging folder
+				content, err := ioutil.ReadFile(filepath.Join(tempDir, filepath.FromSlash(mixin.Filename)))
+				if err != nil {
+					return err
+				}
+
+				if err = staging.WriteFile(mixin.Filename, content, fileMode); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	// Download the remote sources of the Terraform 'module' blocks into the component and use them from there
+	if vendorComponentSpec.Source.LocalizeModules {
+		l.Info("Localizing the remote sources of the Terraform modules")
+
+		if !dryRun {
+			if err = v.localizeModules(v.ctx, staging, filesPolicy); err != nil {
+				return err
 			}
 		}
 	}
 
+	// The symlinks are checked again, since 'mappings', mixins and the localized modules can move them,
+	// and the modes of all staged files are normalized
+	if !dryRun {
+		if err = filesPolicy.checkSymlinks(staging); err != nil {
+			return err
+		}
+
+		if err = filesPolicy.normalizeModes(staging); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-// executeStackVendorCommandInternal executes a stack vendor command
-// TODO: implement this
-func ExecuteStackVendorCommand(
-	fss *fs.FileSystem,
+// rewriteFile applies the matching 'rewrites' to the staged file
+func rewriteFile(l *zap.SugaredLogger, rewrites []contentRewrite, staging fs.FileSystem, file string) error {
+	// The symlinks are not rewritten, the files they point to are rewritten if they are vendored
+	info, err := staging.Lstat(file)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		return nil
+	}
+
+	content, err := staging.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	rewritten := content
+	for _, rewrite := range rewrites {
+		if rewrite.matches(file) {
+			rewritten = rewrite.apply(rewritten)
+		}
+	}
+
+	if bytes.Equal(content, rewritten) {
+		return nil
+	}
+
+	l.Infow("Rewriting the file", "file", file)
+
+	return staging.WriteFile(file, rewritten, info.Mode().Perm())
+}
+
+// stageFile copies the file or the symlink from the source into the staging folder
+func stageFile(staging fs.FileSystem, src string, target string) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		link, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return staging.Symlink(link, target)
+	}
+
+	content, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+
+	return staging.WriteFile(target, content, info.Mode().Perm())
+}
+
+// stageDir copies the files and the symlinks from the folder into the staging folder, skipping the '.git' folders
+func stageDir(staging fs.FileSystem, src string, target string) error {
+	return filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+
+		return stageFile(staging, p, filepath.Join(target, rel))
+	})
+}
+
+// ListComponentVendorFiles downloads the component source and decides for each file if it is vendored,
+// showing which 'included_paths' or 'excluded_paths' rule decided it
+func (v *Vender) ListComponentVendorFiles(vendorComponentSpec config.VendorComponentSpec, component string) ([]PathDecision, error) {
+	l := v.logger.With("component", component)
+
+	if vendorComponentSpec.Source.Uri == "" {
+		return nil, errors.New("'uri' must be specified in 'source.uri' in the 'component.yaml' file")
+	}
+
+	if err := checkSourceType(vendorComponentSpec.Source); err != nil {
+		return nil, err
+	}
+
+	uri, err := v.sourceUri(vendorComponentSpec.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	matcher, err := newPathMatcher(vendorComponentSpec.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	filesPolicy, err := newFilePolicy(vendorComponentSpec.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	tempDir, err := ioutil.TempDir("", strconv.FormatInt(time.Now().Unix(), 10))
+	if err != nil {
+		return nil, err
+	}
+
+	defer func(path string) {
+		err := os.RemoveAll(path)
+		if err != nil {
+			l.Error(err)
+		}
+	}(tempDir)
+
+	l.Infof("Listing sources for the component from '%s'", uri)
+
+	if err = v.downloadSource(v.ctx, vendorComponentSpec.Source, uri, tempDir); err != nil {
+		return nil, err
+	}
+
+	if err = v.checkSourceLimits(uri, tempDir); err != nil {
+		return nil, err
+	}
+
+	if err = filesPolicy.resolveSymlinks(tempDir); err != nil {
+		return nil, err
+	}
+
+	return matchSourceFiles(matcher, tempDir)
+}
+
+// sourceUri returns the 'uri' of the source with the Golang template (e.g. '{{.Version}}') processed
+// and the source alias (e.g. 'cp-components://vpc') expanded
+func (v *Vender) sourceUri(source config.VendorComponentSource) (string, error) {
+	if source.Version == "" {
+		return v.expandSourceAlias(source.Uri, source.Version)
+	}
+
+	t, err := template.New(fmt.Sprintf("source-uri-%s", source.Version)).Parse(source.Uri)
+	if err != nil {
+		return "", err
+	}
+
+	var tpl bytes.Buffer
+	if err = t.Execute(&tpl, source); err != nil {
+		return "", err
+	}
+
+	return v.expandSourceAlias(tpl.String(), source.Version)
+}
+
+// mixinUri returns the 'uri' of the mixin with the Golang template (e.g. '{{.Version}}') processed
+// and the source alias (e.g. 'cp-components://vpc') expanded
+func (v *Vender) mixinUri(mixin config.VendorComponentMixins) (string, error) {
+	if mixin.Version == "" {
+		return v.expandSourceAlias(mixin.Uri, mixin.Version)
+	}
+
+	t, err := template.New(fmt.Sprintf("mixin-uri-%s", mixin.Version)).Parse(mixin.Uri)
+	if err != nil {
+		return "", err
+	}
+
+	var tpl bytes.Buffer
+	if err = t.Execute(&tpl, mixin); err != nil {
+		return "", err
+	}
+
+	return v.expandSourceAlias(tpl.String(), mixin.Version)
+}
+
+// ExecuteStackVendorCommand executes a stack vendor command
+// TODO: implement this, and lock the repo exclusively since all components of the stack are pulled
+func (v *Vender) ExecuteStackVendorCommand(
 	stack string,
 	dryRun bool,
 	vendorCommand string,